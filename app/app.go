@@ -1,29 +1,68 @@
 package app
 
 import (
+	"github.com/marcuswu/msgpack/app/files"
 	"github.com/marcuswu/msgpack/app/firebase"
 	"github.com/marcuswu/msgpack/mobile/router"
+	"github.com/marcuswu/msgpack/mobile/service"
 )
 
-type application struct {
-	router router.Router
-	config firebase.RemoteConfig
+/*
+The functions below configure and read the Default container. They predate Container
+and are kept so platforms and view models built without a container keep working.
+*/
+
+var defaultContainer = NewContainer()
+
+// The container view models use when they aren't given one
+func Default() *Container {
+	return defaultContainer
 }
 
 func SetRouter(router router.Router) {
-	app.router = router
+	defaultContainer.SetRouter(router)
 }
 
 func Router() router.Router {
-	return app.router
+	return defaultContainer.Router()
+}
+
+// The screens navigated to, nil until SetRouter is called
+func BackStack() *router.BackStack {
+	return defaultContainer.BackStack()
 }
 
 func SetConfig(config firebase.RemoteConfig) {
-	app.config = config
+	defaultContainer.SetConfig(config)
 }
 
 func Config() firebase.RemoteConfig {
-	return app.config
+	return defaultContainer.Config()
+}
+
+// Local overrides of remote config values, nil until SetConfig is called
+func ConfigOverrides() *firebase.Overrides {
+	return defaultContainer.ConfigOverrides()
+}
+
+func SetFileProvider(provider files.FileProvider) {
+	defaultContainer.SetFileProvider(provider)
 }
 
-var app = application{}
+func FileProvider() files.FileProvider {
+	return defaultContainer.FileProvider()
+}
+
+// Directory for app-private data such as the recent files list. Empty disables persistence.
+func SetStorageDir(dir string) {
+	defaultContainer.SetStorageDir(dir)
+}
+
+func StorageDir() string {
+	return defaultContainer.StorageDir()
+}
+
+// Follow the background services view models start, see Container.SetServiceCallback
+func SetServiceCallback(callback service.ResourceCallback) {
+	defaultContainer.SetServiceCallback(callback)
+}
@@ -0,0 +1,218 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/marcuswu/msgpack/app/files"
+	"github.com/marcuswu/msgpack/app/firebase"
+	"github.com/marcuswu/msgpack/mobile/router"
+	"github.com/marcuswu/msgpack/mobile/service"
+)
+
+var ErrNoRouter = errors.New("no router has been set")
+
+// Where view models write their diagnostics; *log.Logger is one
+// Only used w/in Go -- Ok to be skipped by gomobile
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+/*
+Container holds the platform services a view model depends on. View models are given
+one when they are built, so Go tests can each build their own with fakes and run in parallel.
+The package level setters (SetRouter, SetConfig, ...) configure the Default container,
+which view models built without one use.
+*/
+type Container struct {
+	mu         sync.RWMutex
+	router     *router.BackStack
+	config     *firebase.Overrides
+	files      files.FileProvider
+	storageDir string
+	logger     Logger
+	jsonValues *firebase.JsonValues
+	services   service.ResourceCallback
+}
+
+// Files default to the os package and logs to the log package so Go tests and desktop runs work without a platform
+func NewContainer() *Container {
+	c := &Container{files: files.NewOSFileProvider(), logger: log.Default()}
+	c.jsonValues = firebase.NewJsonValues(c.Config)
+	return c
+}
+
+// The platform's router is wrapped in a BackStack so navigation can be followed in Go. It logs to the container's Logger.
+func (c *Container) SetRouter(platformRouter router.Router) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.router = router.NewBackStack(platformRouter, routes)
+	c.router.SetLogger(c.logger)
+}
+
+func (c *Container) Router() router.Router {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.router == nil {
+		return nil
+	}
+	return c.router
+}
+
+// The screens navigated to, nil until SetRouter is called
+func (c *Container) BackStack() *router.BackStack {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.router
+}
+
+// The platform's config is wrapped so values can be overridden locally (see ConfigOverrides)
+func (c *Container) SetConfig(config firebase.RemoteConfig) {
+	overrides := firebase.NewOverrides(config)
+	// Activated values replace the ones JsonValues parsed, even where the raw strings match
+	overrides.OnActivate(c.jsonValues.Invalidate)
+	c.mu.Lock()
+	c.config = overrides
+	c.mu.Unlock()
+	c.jsonValues.Invalidate()
+	c.loadConfigOverrides()
+}
+
+func (c *Container) Config() firebase.RemoteConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.config == nil {
+		return nil
+	}
+	return c.config
+}
+
+// Local overrides of remote config values, nil until SetConfig is called
+func (c *Container) ConfigOverrides() *firebase.Overrides {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.config
+}
+
+// Name of the config overrides within StorageDir
+const configOverridesName = "config_overrides.msgpack"
+
+func (c *Container) loadConfigOverrides() {
+	c.mu.RLock()
+	config, storageDir, logger := c.config, c.storageDir, c.logger
+	c.mu.RUnlock()
+	if config == nil || storageDir == "" {
+		return
+	}
+	if err := config.Load(filepath.Join(storageDir, configOverridesName)); err != nil {
+		logger.Printf("Failed to load config overrides: %s\n", err.Error())
+	}
+}
+
+func (c *Container) SetFileProvider(provider files.FileProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files = provider
+}
+
+func (c *Container) FileProvider() files.FileProvider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.files
+}
+
+// Directory for app-private data such as the recent files list. Empty disables persistence.
+func (c *Container) SetStorageDir(dir string) {
+	c.mu.Lock()
+	c.storageDir = dir
+	c.mu.Unlock()
+	c.loadConfigOverrides()
+}
+
+func (c *Container) StorageDir() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.storageDir
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (c *Container) SetLogger(logger Logger) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.logger = logger
+	if c.router != nil {
+		c.router.SetLogger(logger)
+	}
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (c *Container) Logger() Logger {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.logger
+}
+
+/*
+Follow the background services view models start, such as file loads and config fetches.
+View models built after this is set report to callback.
+*/
+func (c *Container) SetServiceCallback(callback service.ResourceCallback) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.services = callback
+}
+
+func (c *Container) ServiceCallback() service.ResourceCallback {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.services
+}
+
+// Parsed GetJson values of this container's config; register a schema for a key before reading it
+func (c *Container) JsonValues() *firebase.JsonValues {
+	return c.jsonValues
+}
+
+// Current values of every flag in this container's config
+func (c *Container) FlagValues() *firebase.FlagValues {
+	return flags.ListFrom(c.Config())
+}
+
+// Validate args against route name and navigate there with them
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (c *Container) Navigate(name string, args *router.Args) error {
+	location, err := routes.Location(name, args)
+	if err != nil {
+		return err
+	}
+	stack := c.BackStack()
+	if stack == nil {
+		return ErrNoRouter
+	}
+	return stack.Push(location)
+}
+
+// Like Navigate, but shows the route in place of the current screen so back doesn't return to it
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (c *Container) Replace(name string, args *router.Args) error {
+	location, err := routes.Location(name, args)
+	if err != nil {
+		return err
+	}
+	stack := c.BackStack()
+	if stack == nil {
+		return ErrNoRouter
+	}
+	return stack.Replace(location)
+}
+
+// Navigate to where link leads, e.g. when another app or a notification opens it
+func (c *Container) OpenLink(link string) error {
+	destination, err := links.Parse(link)
+	if err != nil {
+		return err
+	}
+	return c.Navigate(destination.Name, destination.Args)
+}
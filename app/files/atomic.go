@@ -0,0 +1,101 @@
+package files
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+/*
+WriteAtomic replaces filename with data so that a crash leaves either the old or the new
+contents on disk, never a partial file.
+The data is written to a temp file in the same directory, synced, and renamed over filename.
+If backups > 0 the previous contents are kept as filename.1 (newest) up to filename.<backups>.
+*/
+func WriteAtomic(filename string, data []byte, backups int) error {
+	dir := filepath.Dir(filename)
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("could not create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmpName)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return fmt.Errorf("could not write temp file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return fmt.Errorf("could not sync temp file: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		cleanup()
+		return fmt.Errorf("could not set permissions on temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("could not close temp file: %w", err)
+	}
+
+	if backups > 0 {
+		if err := rotateBackups(filename, backups); err != nil {
+			os.Remove(tmpName)
+			return err
+		}
+	}
+
+	if err := os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("could not replace %s: %w", filename, err)
+	}
+	return syncDir(dir)
+}
+
+// Name of the nth backup of filename (1 is the newest)
+func BackupName(filename string, n int) string {
+	return fmt.Sprintf("%s.%d", filename, n)
+}
+
+// Shift filename.1 .. filename.<backups-1> up by one and copy filename to filename.1
+func rotateBackups(filename string, backups int) error {
+	current, err := os.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read %s for backup: %w", filename, err)
+	}
+
+	for n := backups - 1; n >= 1; n-- {
+		err := os.Rename(BackupName(filename, n), BackupName(filename, n+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("could not rotate backup %d: %w", n, err)
+		}
+	}
+	// Copy rather than rename so filename always exists until the new contents replace it
+	if err := os.WriteFile(BackupName(filename, 1), current, 0600); err != nil {
+		return fmt.Errorf("could not write backup: %w", err)
+	}
+	return nil
+}
+
+// Sync a directory so a rename within it is durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	// Some filesystems don't support syncing directories; the rename has still happened
+	d.Sync()
+	return nil
+}
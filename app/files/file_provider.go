@@ -0,0 +1,91 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Metadata for a file. Modified is unix milliseconds.
+type FileInfo struct {
+	Uri      string
+	Name     string
+	Size     int64
+	Modified int64
+	MimeType string
+	Exists   bool
+}
+
+// A file opened for reading. ReadChunk returns an empty slice once the end of the file is reached.
+type FileReader interface {
+	ReadChunk(maxBytes int) ([]byte, error)
+	Close() error
+}
+
+/*
+FileProvider gives Go access to files through the platform.
+On Android uris are usually Storage Access Framework content:// uris, which can't be
+reached with the os package, so all file I/O in the view models goes through here.
+Stat reports a missing file with Exists set to false rather than an error.
+Write replaces the contents of uri, keeping up to backups previous versions where the platform can.
+*/
+type FileProvider interface {
+	Stat(uri string) (*FileInfo, error)
+	Open(uri string) (FileReader, error)
+	Write(uri string, data []byte, backups int) error
+	TakePersistablePermission(uri string) error
+	ReleasePersistablePermission(uri string) error
+}
+
+// Size of the chunks ReadAll asks the platform for
+const readChunkSize = 64 * 1024
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func ReadAll(provider FileProvider, uri string) ([]byte, error) {
+	return ReadAllContext(context.Background(), provider, uri, nil)
+}
+
+/*
+ReadAllContext reads uri a chunk at a time, stopping with ctx.Err() once ctx is done.
+progress, when not nil, is called after each chunk with the bytes read so far and
+the size of the file, or -1 when the platform doesn't know it.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func ReadAllContext(ctx context.Context, provider FileProvider, uri string, progress func(read int64, total int64)) ([]byte, error) {
+	if provider == nil {
+		return nil, errors.New("no file provider has been set")
+	}
+	total := int64(-1)
+	if progress != nil {
+		if info, err := provider.Stat(uri); err == nil && info.Exists {
+			total = info.Size
+		}
+	}
+	reader, err := provider.Open(uri)
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s: %w", uri, err)
+	}
+	defer reader.Close()
+
+	var buf bytes.Buffer
+	if total > 0 {
+		buf.Grow(int(total))
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		chunk, err := reader.ReadChunk(readChunkSize)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", uri, err)
+		}
+		if len(chunk) == 0 {
+			return buf.Bytes(), nil
+		}
+		buf.Write(chunk)
+		if progress != nil {
+			progress(int64(buf.Len()), total)
+		}
+	}
+}
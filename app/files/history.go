@@ -0,0 +1,190 @@
+package files
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	historyDirName   = "history"
+	historyIndexName = "index.msgpack"
+	historyObjects   = "objects"
+)
+
+// Most versions kept per file; older ones are dropped as new ones are recorded
+const defaultMaxVersions = 50
+
+/*
+Version is one saved version of a file.
+Id is the sha256 of the saved bytes, which are stored once however many versions share them.
+Saved is unix milliseconds; Format is the viewer format the file was saved in.
+*/
+type Version struct {
+	Id      string `msgpack:"id"`
+	Saved   int64  `msgpack:"saved"`
+	Message string `msgpack:"message,omitempty"`
+	Size    int64  `msgpack:"size"`
+	Format  int    `msgpack:"format"`
+}
+
+// Versions lists saved versions, newest first
+type Versions struct {
+	versions []*Version
+}
+
+func (v *Versions) Size() int {
+	return len(v.versions)
+}
+
+func (v *Versions) Get(i int) *Version {
+	if i < 0 || i >= len(v.versions) {
+		return nil
+	}
+	return v.versions[i]
+}
+
+func (v *Versions) Find(id string) *Version {
+	for _, version := range v.versions {
+		if version.Id == id {
+			return version
+		}
+	}
+	return nil
+}
+
+func (v *Versions) Clone() *Versions {
+	c := &Versions{versions: make([]*Version, 0, len(v.versions))}
+	for _, version := range v.versions {
+		copied := *version
+		c.versions = append(c.versions, &copied)
+	}
+	return c
+}
+
+/*
+History keeps the saved versions of one file in app-private storage.
+Each version's bytes live in objects/ named by their hash, next to an index of versions.
+*/
+type History struct {
+	dir         string
+	maxVersions int
+}
+
+func historyDir(storageDir string, uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(storageDir, historyDirName, hex.EncodeToString(sum[:8]))
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func OpenHistory(storageDir string, uri string) *History {
+	return &History{dir: historyDir(storageDir, uri), maxVersions: defaultMaxVersions}
+}
+
+func (h *History) SetMaxVersions(max int) {
+	if max < 1 {
+		max = 1
+	}
+	h.maxVersions = max
+}
+
+// The recorded versions, newest first
+func (h *History) Versions() (*Versions, error) {
+	data, err := os.ReadFile(filepath.Join(h.dir, historyIndexName))
+	if errors.Is(err, os.ErrNotExist) {
+		return &Versions{}, nil
+	}
+	if err != nil {
+		return &Versions{}, err
+	}
+	versions := &Versions{}
+	if err := msgpack.Unmarshal(data, &versions.versions); err != nil {
+		return &Versions{}, fmt.Errorf("could not read version history: %w", err)
+	}
+	return versions, nil
+}
+
+/*
+Record adds data as the newest version. Saving the same bytes as the newest version
+again doesn't add a version; a message given that time replaces the old one.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func (h *History) Record(data []byte, format int, message string) (*Version, error) {
+	versions, err := h.Versions()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+	if latest := versions.Get(0); latest != nil && latest.Id == id {
+		if message == "" || message == latest.Message {
+			return latest, nil
+		}
+		latest.Message = message
+		return latest, h.writeIndex(versions)
+	}
+
+	if err := os.MkdirAll(filepath.Join(h.dir, historyObjects), 0700); err != nil {
+		return nil, err
+	}
+	object := h.objectPath(id)
+	if _, err := os.Stat(object); errors.Is(err, os.ErrNotExist) {
+		if err := WriteAtomic(object, data, 0); err != nil {
+			return nil, err
+		}
+	}
+	version := &Version{Id: id, Saved: time.Now().UnixMilli(), Message: message, Size: int64(len(data)), Format: format}
+	versions.versions = append([]*Version{version}, versions.versions...)
+	var dropped []*Version
+	if len(versions.versions) > h.maxVersions {
+		dropped = versions.versions[h.maxVersions:]
+		versions.versions = versions.versions[:h.maxVersions]
+	}
+	if err := h.writeIndex(versions); err != nil {
+		return nil, err
+	}
+	h.removeUnused(versions, dropped)
+	return version, nil
+}
+
+// The bytes saved as version id
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (h *History) Load(id string) ([]byte, error) {
+	data, err := os.ReadFile(h.objectPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no saved version %s", id)
+	}
+	return data, err
+}
+
+// Remove every recorded version
+func (h *History) Clear() error {
+	return os.RemoveAll(h.dir)
+}
+
+func (h *History) objectPath(id string) string {
+	return filepath.Join(h.dir, historyObjects, id)
+}
+
+func (h *History) writeIndex(versions *Versions) error {
+	data, err := msgpack.Marshal(versions.versions)
+	if err != nil {
+		return err
+	}
+	return WriteAtomic(filepath.Join(h.dir, historyIndexName), data, 0)
+}
+
+// Delete the objects of dropped versions that no kept version shares
+func (h *History) removeUnused(kept *Versions, dropped []*Version) {
+	for _, version := range dropped {
+		if kept.Find(version.Id) == nil {
+			os.Remove(h.objectPath(version.Id))
+		}
+	}
+}
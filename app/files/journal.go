@@ -0,0 +1,292 @@
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	journalDirName  = "journal"
+	sessionMetaName = "session.msgpack"
+	draftName       = "draft.msgpack"
+	editsName       = "edits.log"
+)
+
+// Snapshot the draft after this many journaled edits or this much time, whichever comes first
+const (
+	snapshotEveryEdits = 50
+	snapshotEvery      = 30 * time.Second
+)
+
+const (
+	JournalSet    = "set"
+	JournalFormat = "format"
+)
+
+/*
+JournalEntry is one edit. For JournalSet, Path, Key and Value are the arguments the
+viewer's SetPath was called with; for JournalFormat, Format is the new format.
+Value is always written, as setting 0, false or "" is an edit too.
+*/
+type JournalEntry struct {
+	Op     string      `msgpack:"op"`
+	Path   string      `msgpack:"path,omitempty"`
+	Key    string      `msgpack:"key,omitempty"`
+	Value  interface{} `msgpack:"value"`
+	Format int         `msgpack:"format,omitempty"`
+}
+
+// Draft is a snapshot of a document. Data is the document encoded as msgpack.
+type Draft struct {
+	Format int    `msgpack:"format"`
+	Data   []byte `msgpack:"data"`
+}
+
+type sessionMeta struct {
+	Uri     string `msgpack:"uri"`
+	Created int64  `msgpack:"created"`
+}
+
+/*
+Journal is a write-ahead log of the unsaved edits to one file, kept in app-private
+storage so an edit session survives the process being killed.
+Each session has a draft snapshot plus the edits made since; replaying the edits on
+top of the draft gives the document as it was when the process died.
+*/
+type Journal struct {
+	dir          string
+	uri          string
+	log          *os.File
+	edits        int
+	lastSnapshot time.Time
+}
+
+func journalRoot(storageDir string) string {
+	return filepath.Join(storageDir, journalDirName)
+}
+
+func sessionDir(storageDir string, uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(journalRoot(storageDir), hex.EncodeToString(sum[:8]))
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func OpenJournal(storageDir string, uri string) *Journal {
+	return &Journal{dir: sessionDir(storageDir, uri), uri: uri}
+}
+
+// Whether the journal holds a draft, i.e. there are edits that haven't been saved
+func (j *Journal) Active() bool {
+	return j.log != nil
+}
+
+// Start a session with draft as the document before any edits
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (j *Journal) Start(draft *Draft) error {
+	if err := os.MkdirAll(j.dir, 0700); err != nil {
+		return err
+	}
+	meta, err := msgpack.Marshal(&sessionMeta{Uri: j.uri, Created: time.Now().UnixMilli()})
+	if err != nil {
+		return err
+	}
+	if err := WriteAtomic(filepath.Join(j.dir, sessionMetaName), meta, 0); err != nil {
+		return err
+	}
+	return j.Snapshot(draft)
+}
+
+// Append an edit and sync it to disk before returning
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (j *Journal) Append(entry *JournalEntry) error {
+	if j.log == nil {
+		return errors.New("journal has not been started")
+	}
+	record, err := msgpack.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := j.log.Write(record); err != nil {
+		return err
+	}
+	j.edits++
+	return j.log.Sync()
+}
+
+// Whether enough has changed since the last snapshot that a new one should be taken
+func (j *Journal) ShouldSnapshot() bool {
+	return j.edits >= snapshotEveryEdits || (j.edits > 0 && time.Since(j.lastSnapshot) >= snapshotEvery)
+}
+
+// Replace the draft with the current document and empty the edit log
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (j *Journal) Snapshot(draft *Draft) error {
+	data, err := msgpack.Marshal(draft)
+	if err != nil {
+		return err
+	}
+	if err := WriteAtomic(filepath.Join(j.dir, draftName), data, 0); err != nil {
+		return err
+	}
+	if j.log != nil {
+		j.log.Close()
+	}
+	// The draft now includes every logged edit, so the log starts over
+	j.log, err = os.OpenFile(filepath.Join(j.dir, editsName), os.O_CREATE|os.O_TRUNC|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	j.edits = 0
+	j.lastSnapshot = time.Now()
+	return nil
+}
+
+// Remove the session, e.g. once the document has been saved or the user discards the recovery
+func (j *Journal) Discard() error {
+	if j.log != nil {
+		j.log.Close()
+		j.log = nil
+	}
+	j.edits = 0
+	return os.RemoveAll(j.dir)
+}
+
+func (j *Journal) Close() error {
+	if j.log == nil {
+		return nil
+	}
+	err := j.log.Close()
+	j.log = nil
+	return err
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func DiscardSession(storageDir string, uri string) error {
+	return os.RemoveAll(sessionDir(storageDir, uri))
+}
+
+/*
+LoadSession reads back the draft and edits of an interrupted session.
+A partially written final edit (the process died mid-write) is dropped.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func LoadSession(storageDir string, uri string) (*Draft, []*JournalEntry, error) {
+	dir := sessionDir(storageDir, uri)
+	data, err := os.ReadFile(filepath.Join(dir, draftName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read draft: %w", err)
+	}
+	draft := &Draft{}
+	if err := msgpack.Unmarshal(data, draft); err != nil {
+		return nil, nil, fmt.Errorf("could not decode draft: %w", err)
+	}
+
+	entries := make([]*JournalEntry, 0)
+	data, err = os.ReadFile(filepath.Join(dir, editsName))
+	if errors.Is(err, os.ErrNotExist) {
+		return draft, entries, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read edits: %w", err)
+	}
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	for {
+		entry := &JournalEntry{}
+		// Stop at the end of the log, or at a torn write from the process dying mid-append
+		if err := dec.Decode(entry); err != nil {
+			break
+		}
+		entries = append(entries, entry)
+	}
+	return draft, entries, nil
+}
+
+/*
+Session is an interrupted edit session that can be recovered.
+Edits counts the edits logged since the last draft snapshot; Modified is unix milliseconds.
+*/
+type Session struct {
+	Uri      string
+	Name     string
+	Edits    int
+	Modified int64
+}
+
+// Sessions lists interrupted sessions, most recently modified first
+type Sessions struct {
+	sessions []*Session
+}
+
+func (s *Sessions) Size() int {
+	return len(s.sessions)
+}
+
+func (s *Sessions) Get(i int) *Session {
+	if i < 0 || i >= len(s.sessions) {
+		return nil
+	}
+	return s.sessions[i]
+}
+
+func (s *Sessions) Clone() *Sessions {
+	return &Sessions{sessions: append([]*Session{}, s.sessions...)}
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (s *Sessions) Remove(uri string) {
+	for i, session := range s.sessions {
+		if session.Uri == uri {
+			s.sessions = append(s.sessions[:i], s.sessions[i+1:]...)
+			return
+		}
+	}
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func ListSessions(storageDir string) (*Sessions, error) {
+	result := &Sessions{}
+	dirs, err := os.ReadDir(journalRoot(storageDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(journalRoot(storageDir), d.Name())
+		data, err := os.ReadFile(filepath.Join(dir, sessionMetaName))
+		if err != nil {
+			continue
+		}
+		meta := &sessionMeta{}
+		if err := msgpack.Unmarshal(data, meta); err != nil {
+			continue
+		}
+		session := &Session{Uri: meta.Uri, Name: filepath.Base(meta.Uri), Modified: meta.Created}
+		if _, entries, err := LoadSession(storageDir, meta.Uri); err == nil {
+			session.Edits = len(entries)
+		}
+		for _, name := range []string{draftName, editsName} {
+			if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.ModTime().UnixMilli() > session.Modified {
+				session.Modified = info.ModTime().UnixMilli()
+			}
+		}
+		result.sessions = append(result.sessions, session)
+	}
+	sort.Slice(result.sessions, func(i, j int) bool {
+		return result.sessions[i].Modified > result.sessions[j].Modified
+	})
+	return result, nil
+}
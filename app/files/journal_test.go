@@ -0,0 +1,44 @@
+package files
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJournalReplaysZeroValues(t *testing.T) {
+	dir := t.TempDir()
+	uri := "content://docs/config.msgpack"
+	journal := OpenJournal(dir, uri)
+	if err := journal.Start(&Draft{Format: 0, Data: []byte{0x80}}); err != nil {
+		t.Fatal(err)
+	}
+	written := []*JournalEntry{
+		{Op: JournalSet, Path: "", Key: "count", Value: int8(0)},
+		{Op: JournalSet, Path: "", Key: "enabled", Value: false},
+		{Op: JournalSet, Path: "", Key: "name", Value: ""},
+		{Op: JournalSet, Path: "", Key: "ratio", Value: 0.0},
+		{Op: JournalSet, Path: "", Key: "missing", Value: nil},
+		{Op: JournalFormat, Format: 2},
+	}
+	for _, entry := range written {
+		if err := journal.Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := journal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, replayed, err := LoadSession(dir, uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != len(written) {
+		t.Fatalf("replayed %d edits, want %d", len(replayed), len(written))
+	}
+	for i, entry := range replayed {
+		if !reflect.DeepEqual(entry, written[i]) {
+			t.Errorf("edit %d replayed as %+v, want %+v", i, entry, written[i])
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package files
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/*
+OSFileProvider is a FileProvider backed by the os package.
+It is used for Go tests and desktop runs; uris are plain paths or file:// uris.
+Persistable permissions don't apply to local paths, so those calls do nothing.
+*/
+type OSFileProvider struct{}
+
+func NewOSFileProvider() *OSFileProvider {
+	return &OSFileProvider{}
+}
+
+func osPath(uri string) string {
+	return strings.TrimPrefix(uri, "file://")
+}
+
+func (p *OSFileProvider) Stat(uri string) (*FileInfo, error) {
+	path := osPath(uri)
+	info := &FileInfo{Uri: uri, Name: filepath.Base(path), MimeType: mime.TypeByExtension(filepath.Ext(path))}
+	stat, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return info, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info.Exists = true
+	info.Size = stat.Size()
+	info.Modified = stat.ModTime().UnixMilli()
+	return info, nil
+}
+
+func (p *OSFileProvider) Open(uri string) (FileReader, error) {
+	f, err := os.Open(osPath(uri))
+	if err != nil {
+		return nil, err
+	}
+	return &osFileReader{file: f}, nil
+}
+
+func (p *OSFileProvider) Write(uri string, data []byte, backups int) error {
+	return WriteAtomic(osPath(uri), data, backups)
+}
+
+func (p *OSFileProvider) TakePersistablePermission(uri string) error {
+	return nil
+}
+
+func (p *OSFileProvider) ReleasePersistablePermission(uri string) error {
+	return nil
+}
+
+type osFileReader struct {
+	file *os.File
+}
+
+func (r *osFileReader) ReadChunk(maxBytes int) ([]byte, error) {
+	chunk := make([]byte, maxBytes)
+	n, err := io.ReadFull(r.file, chunk)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	return chunk[:n], err
+}
+
+func (r *osFileReader) Close() error {
+	return r.file.Close()
+}
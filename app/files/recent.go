@@ -0,0 +1,194 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Most unpinned entries a RecentFiles keeps; pinned entries don't count towards it
+const maxRecentFiles = 20
+
+/*
+RecentFile is an entry in the recent files list.
+Format is a codec name (logic.MsgPackCodec etc.) or "" when unknown, LastOpened is unix milliseconds.
+Missing is set when the last check found the file no longer exists.
+*/
+type RecentFile struct {
+	Uri        string `msgpack:"uri"`
+	Name       string `msgpack:"name"`
+	Format     string `msgpack:"format"`
+	Size       int64  `msgpack:"size"`
+	LastOpened int64  `msgpack:"last_opened"`
+	Pinned     bool   `msgpack:"pinned"`
+	Missing    bool   `msgpack:"-"`
+}
+
+func (f *RecentFile) clone() *RecentFile {
+	c := *f
+	return &c
+}
+
+// RecentFiles keeps pinned entries first, then the most recently opened
+type RecentFiles struct {
+	entries []*RecentFile
+}
+
+func NewRecentFiles() *RecentFiles {
+	return &RecentFiles{}
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func LoadRecentFiles(filename string) (*RecentFiles, error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewRecentFiles(), nil
+	}
+	if err != nil {
+		return NewRecentFiles(), err
+	}
+	r := NewRecentFiles()
+	if err := msgpack.Unmarshal(data, &r.entries); err != nil {
+		return NewRecentFiles(), fmt.Errorf("could not read recent files: %w", err)
+	}
+	r.sort()
+	return r, nil
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (r *RecentFiles) Save(filename string) error {
+	data, err := msgpack.Marshal(r.entries)
+	if err != nil {
+		return err
+	}
+	return WriteAtomic(filename, data, 0)
+}
+
+func (r *RecentFiles) Clone() *RecentFiles {
+	c := &RecentFiles{entries: make([]*RecentFile, 0, len(r.entries))}
+	for _, entry := range r.entries {
+		c.entries = append(c.entries, entry.clone())
+	}
+	return c
+}
+
+func (r *RecentFiles) Size() int {
+	return len(r.entries)
+}
+
+func (r *RecentFiles) Get(i int) *RecentFile {
+	if i < 0 || i >= len(r.entries) {
+		return nil
+	}
+	return r.entries[i]
+}
+
+func (r *RecentFiles) Find(uri string) *RecentFile {
+	for _, entry := range r.entries {
+		if entry.Uri == uri {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Record that info was opened, moving it to the top of the unpinned entries
+func (r *RecentFiles) Opened(info *FileInfo, format string) {
+	entry := r.Find(info.Uri)
+	if entry == nil {
+		entry = &RecentFile{Uri: info.Uri}
+		r.entries = append(r.entries, entry)
+	}
+	entry.Name = info.Name
+	entry.Size = info.Size
+	entry.Missing = !info.Exists
+	if format != "" {
+		entry.Format = format
+	}
+	entry.LastOpened = time.Now().UnixMilli()
+	r.sort()
+	r.trim()
+}
+
+func (r *RecentFiles) Remove(uri string) {
+	for i, entry := range r.entries {
+		if entry.Uri == uri {
+			r.entries = append(r.entries[:i], r.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Remove every entry that isn't pinned
+func (r *RecentFiles) Clear() {
+	pinned := make([]*RecentFile, 0)
+	for _, entry := range r.entries {
+		if entry.Pinned {
+			pinned = append(pinned, entry)
+		}
+	}
+	r.entries = pinned
+}
+
+func (r *RecentFiles) SetPinned(uri string, pinned bool) {
+	if entry := r.Find(uri); entry != nil {
+		entry.Pinned = pinned
+		r.sort()
+		r.trim()
+	}
+}
+
+// Re-check every entry with provider, setting Missing on those that no longer exist
+func (r *RecentFiles) Refresh(provider FileProvider) {
+	for _, entry := range r.entries {
+		info, err := provider.Stat(entry.Uri)
+		if err != nil {
+			// Can't tell (e.g. a revoked permission); treat it as missing so the UI can offer removal
+			entry.Missing = true
+			continue
+		}
+		entry.Missing = !info.Exists
+		if info.Exists {
+			entry.Size = info.Size
+		}
+	}
+}
+
+// Remove every entry that was found to be missing, including pinned ones
+func (r *RecentFiles) RemoveMissing() {
+	present := make([]*RecentFile, 0, len(r.entries))
+	for _, entry := range r.entries {
+		if !entry.Missing {
+			present = append(present, entry)
+		}
+	}
+	r.entries = present
+}
+
+func (r *RecentFiles) sort() {
+	sort.SliceStable(r.entries, func(i, j int) bool {
+		if r.entries[i].Pinned != r.entries[j].Pinned {
+			return r.entries[i].Pinned
+		}
+		return r.entries[i].LastOpened > r.entries[j].LastOpened
+	})
+}
+
+// Drop the oldest unpinned entries past maxRecentFiles. Entries must be sorted.
+func (r *RecentFiles) trim() {
+	unpinned := 0
+	for i, entry := range r.entries {
+		if entry.Pinned {
+			continue
+		}
+		unpinned++
+		if unpinned > maxRecentFiles {
+			r.entries = r.entries[:i]
+			return
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package firebase
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/marcuswu/msgpack/app/logic"
+)
+
+/*
+FileConfig is a RemoteConfig read from a local JSON, YAML or msgpack file, for Go tests
+and desktop runs where there is no Firebase. The file has two maps:
+
+	defaults: values used until a fetch has been activated
+	values:   what a fetch activates, as if it came from the Firebase console
+
+The file is read again on every fetch so edits show up without restarting.
+Fetches can be slowed down, made to fail, or made to never answer, and every
+key read is counted so tests can check which flags a screen depends on.
+*/
+type FileConfig struct {
+	mu        sync.Mutex
+	filename  string
+	defaults  map[string]interface{}
+	values    map[string]interface{}
+	activated map[string]interface{}
+	latency   time.Duration
+	fail      bool
+	hang      bool
+	reads     map[string]int
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewFileConfig(filename string) (*FileConfig, error) {
+	c := &FileConfig{filename: filename, reads: make(map[string]int)}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *FileConfig) load() error {
+	fileData, err := os.ReadFile(c.filename)
+	if err != nil {
+		return err
+	}
+	var data *logic.Field
+	switch logic.DetectCodec(fileData) {
+	case logic.MsgPackCodec:
+		data, err = logic.DecodeMsgPack(fileData)
+	case logic.JsonCodec:
+		data, err = logic.DecodeJson(fileData)
+	default:
+		data, err = logic.DecodeYaml(fileData)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read config %s: %w", c.filename, err)
+	}
+	document := data.Value().(map[string]interface{})
+	defaults, err := configSection(document, "defaults")
+	if err != nil {
+		return err
+	}
+	values, err := configSection(document, "values")
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.defaults = defaults
+	c.values = values
+	return nil
+}
+
+func configSection(document map[string]interface{}, name string) (map[string]interface{}, error) {
+	section, ok := document[name]
+	if !ok || section == nil {
+		return make(map[string]interface{}), nil
+	}
+	values, ok := section.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("config %s must be a map", name)
+	}
+	return values, nil
+}
+
+// How long fetches take to answer
+func (c *FileConfig) SetFetchLatency(millis int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = time.Duration(millis) * time.Millisecond
+}
+
+// Make fetches answer false, as when the device is offline
+func (c *FileConfig) SetFetchFails(fail bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fail = fail
+}
+
+// Make fetches never answer, to exercise timeouts
+func (c *FileConfig) SetFetchHangs(hang bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.hang = hang
+}
+
+func (c *FileConfig) FetchAndActivate(callback *ActivateCallback) {
+	c.mu.Lock()
+	latency, fail, hang := c.latency, c.fail, c.hang
+	c.mu.Unlock()
+	if hang {
+		return
+	}
+	go func() {
+		time.Sleep(latency)
+		if fail {
+			callback.OnActivate(false)
+			return
+		}
+		if err := c.load(); err != nil {
+			callback.OnActivate(false)
+			return
+		}
+		c.mu.Lock()
+		c.activated = c.values
+		c.mu.Unlock()
+		callback.OnActivate(true)
+	}()
+}
+
+// The value of key and where it came from, counting the read
+func (c *FileConfig) read(key string) (interface{}, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads[key]++
+	if value, ok := c.activated[key]; ok {
+		return value, SourceRemote
+	}
+	if value, ok := c.defaults[key]; ok {
+		return value, SourceDefault
+	}
+	return nil, SourceDefault
+}
+
+// Values are converted to strings the way Firebase delivers them; maps and arrays become JSON
+func (c *FileConfig) GetStr(key string) string {
+	value, _ := c.read(key)
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, err := logic.EncodeJson(v)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func (c *FileConfig) GetBool(key string) bool {
+	b, _ := strconv.ParseBool(c.GetStr(key))
+	return b
+}
+
+func (c *FileConfig) GetFloat64(key string) float64 {
+	f, _ := strconv.ParseFloat(c.GetStr(key), 64)
+	return f
+}
+
+func (c *FileConfig) GetInt(key string) int {
+	i, _ := strconv.Atoi(c.GetStr(key))
+	return i
+}
+
+func (c *FileConfig) GetJson(key string) string {
+	return c.GetStr(key)
+}
+
+// Implements ValueSource. Checking the source doesn't count as a read.
+func (c *FileConfig) GetSource(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.activated[key]; ok {
+		return SourceRemote
+	}
+	return SourceDefault
+}
+
+// How many times key has been read
+func (c *FileConfig) ReadCount(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reads[key]
+}
+
+// Every key read so far, in order
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (c *FileConfig) ReadKeys() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := make([]string, 0, len(c.reads))
+	for key := range c.reads {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c *FileConfig) ResetReads() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reads = make(map[string]int)
+}
@@ -0,0 +1,457 @@
+package firebase
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/marcuswu/msgpack/app/logic"
+)
+
+// Types of flag
+const (
+	BoolType = iota
+	IntType
+	FloatType
+	StringType
+	JsonType
+)
+
+func FlagTypeString(flagType int) string {
+	switch flagType {
+	case BoolType:
+		return "bool"
+	case IntType:
+		return "int"
+	case FloatType:
+		return "float"
+	case StringType:
+		return "string"
+	case JsonType:
+		return "json"
+	}
+	return "unknown"
+}
+
+// Where a flag's value came from
+const (
+	SourceDefault = iota
+	SourceRemote
+	SourceOverride
+)
+
+func SourceString(source int) string {
+	switch source {
+	case SourceDefault:
+		return "default"
+	case SourceRemote:
+		return "remote"
+	case SourceOverride:
+		return "override"
+	}
+	return "unknown"
+}
+
+/*
+ValueSource can be implemented by a RemoteConfig that knows where each value comes from
+(one of SourceDefault, SourceRemote or SourceOverride). A key has a value when GetStr returns
+anything, since Firebase returns "" for keys it has no value for; without ValueSource every
+value counts as remote.
+*/
+type ValueSource interface {
+	GetSource(key string) int
+}
+
+// A flag's current value for display, e.g. on a debug screen. Value and Default are formatted as strings.
+type FlagValue struct {
+	Key         string
+	Type        int
+	Description string
+	Value       string
+	Default     string
+	Source      int
+	// Why the configured value was rejected in favour of the default, "" when it wasn't
+	Error string
+}
+
+// FlagValues lists flags in key order
+type FlagValues struct {
+	values []*FlagValue
+}
+
+func (v *FlagValues) Size() int {
+	return len(v.values)
+}
+
+func (v *FlagValues) Get(i int) *FlagValue {
+	if i < 0 || i >= len(v.values) {
+		return nil
+	}
+	return v.values[i]
+}
+
+func (v *FlagValues) Find(key string) *FlagValue {
+	for _, value := range v.values {
+		if value.Key == key {
+			return value
+		}
+	}
+	return nil
+}
+
+type flag struct {
+	key          string
+	flagType     int
+	description  string
+	defaultValue interface{}
+	// Parses and checks the configured value
+	parse func(raw string) (interface{}, error)
+}
+
+// The configured value of fl as a string. It is read with a single call so that each read of a flag is one read of the config.
+func (fl *flag) raw(config RemoteConfig) string {
+	if fl.flagType == JsonType {
+		return config.GetJson(fl.key)
+	}
+	return config.GetStr(fl.key)
+}
+
+/*
+Flags is a registry of the feature flags the app reads from remote config.
+Each flag is declared once with its key, type, default and description, and read
+through a typed handle rather than a raw key. A value that can't be used (wrong
+type, failed validation) is ignored in favour of the default.
+*/
+type Flags struct {
+	mu     sync.Mutex
+	config func() RemoteConfig
+	flags  map[string]*flag
+}
+
+// config is called on every read so the registry follows whichever RemoteConfig is current
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewFlags(config func() RemoteConfig) *Flags {
+	return &Flags{config: config, flags: make(map[string]*flag)}
+}
+
+func (f *Flags) register(fl *flag) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.flags[fl.key]; ok {
+		panic(fmt.Sprintf("flag %s is declared twice", fl.key))
+	}
+	f.flags[fl.key] = fl
+}
+
+// The value of fl, where it came from, and why the configured value was rejected if it was
+func (f *Flags) value(fl *flag) (interface{}, int, error) {
+	return f.valueIn(fl, f.config())
+}
+
+// As value, reading config rather than the registry's
+func (f *Flags) valueIn(fl *flag, config RemoteConfig) (interface{}, int, error) {
+	if config == nil {
+		return fl.defaultValue, SourceDefault, nil
+	}
+	raw := fl.raw(config)
+	if raw == "" {
+		return fl.defaultValue, SourceDefault, nil
+	}
+	// A value the config reports as a default is its in-app default, which wins over the declared one
+	source := SourceRemote
+	if sourced, ok := config.(ValueSource); ok {
+		source = sourced.GetSource(fl.key)
+	}
+	value, err := fl.parse(raw)
+	if err != nil {
+		return fl.defaultValue, SourceDefault, fmt.Errorf("%s value for %s rejected: %w", SourceString(source), fl.key, err)
+	}
+	return value, source, nil
+}
+
+// Current values of every flag, for a debug screen
+func (f *Flags) List() *FlagValues {
+	return f.ListFrom(f.config())
+}
+
+// Values of every flag as read from config
+func (f *Flags) ListFrom(config RemoteConfig) *FlagValues {
+	f.mu.Lock()
+	flags := make([]*flag, 0, len(f.flags))
+	for _, fl := range f.flags {
+		flags = append(flags, fl)
+	}
+	f.mu.Unlock()
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].key < flags[j].key
+	})
+
+	values := &FlagValues{values: make([]*FlagValue, 0, len(flags))}
+	for _, fl := range flags {
+		value, source, err := f.valueIn(fl, config)
+		flagValue := &FlagValue{
+			Key:         fl.key,
+			Type:        fl.flagType,
+			Description: fl.description,
+			Value:       formatFlagValue(value),
+			Default:     formatFlagValue(fl.defaultValue),
+			Source:      source,
+		}
+		if err != nil {
+			flagValue.Error = err.Error()
+		}
+		values.values = append(values.values, flagValue)
+	}
+	return values
+}
+
+// Whether key is a declared flag, and its type
+func (f *Flags) Lookup(key string) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fl, ok := f.flags[key]
+	if !ok {
+		return 0, false
+	}
+	return fl.flagType, true
+}
+
+func formatFlagValue(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return v
+	}
+	return fmt.Sprint(value)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+type BoolFlag struct {
+	flags *Flags
+	flag  *flag
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (f *Flags) Bool(key string, defaultValue bool, description string) *BoolFlag {
+	fl := &flag{key: key, flagType: BoolType, description: description, defaultValue: defaultValue}
+	fl.parse = func(raw string) (interface{}, error) {
+		// GetBool can't tell false from a value that isn't a bool, so the raw string is parsed instead
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a bool", raw)
+		}
+		return value, nil
+	}
+	f.register(fl)
+	return &BoolFlag{flags: f, flag: fl}
+}
+
+func (f *BoolFlag) Key() string {
+	return f.flag.key
+}
+
+func (f *BoolFlag) Get() bool {
+	value, _, _ := f.flags.value(f.flag)
+	return value.(bool)
+}
+
+// The value as read from config rather than the registry's
+func (f *BoolFlag) GetFrom(config RemoteConfig) bool {
+	value, _, _ := f.flags.valueIn(f.flag, config)
+	return value.(bool)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+type IntFlag struct {
+	flags *Flags
+	flag  *flag
+}
+
+// validate may be nil
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (f *Flags) Int(key string, defaultValue int, description string, validate func(int) error) *IntFlag {
+	fl := &flag{key: key, flagType: IntType, description: description, defaultValue: defaultValue}
+	fl.parse = func(raw string) (interface{}, error) {
+		value, err := strconv.Atoi(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an int", raw)
+		}
+		if validate != nil {
+			if err := validate(value); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	f.register(fl)
+	return &IntFlag{flags: f, flag: fl}
+}
+
+func (f *IntFlag) Key() string {
+	return f.flag.key
+}
+
+func (f *IntFlag) Get() int {
+	value, _, _ := f.flags.value(f.flag)
+	return value.(int)
+}
+
+// The value as read from config rather than the registry's
+func (f *IntFlag) GetFrom(config RemoteConfig) int {
+	value, _, _ := f.flags.valueIn(f.flag, config)
+	return value.(int)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+type FloatFlag struct {
+	flags *Flags
+	flag  *flag
+}
+
+// validate may be nil
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (f *Flags) Float(key string, defaultValue float64, description string, validate func(float64) error) *FloatFlag {
+	fl := &flag{key: key, flagType: FloatType, description: description, defaultValue: defaultValue}
+	fl.parse = func(raw string) (interface{}, error) {
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", raw)
+		}
+		if validate != nil {
+			if err := validate(value); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	f.register(fl)
+	return &FloatFlag{flags: f, flag: fl}
+}
+
+func (f *FloatFlag) Key() string {
+	return f.flag.key
+}
+
+func (f *FloatFlag) Get() float64 {
+	value, _, _ := f.flags.value(f.flag)
+	return value.(float64)
+}
+
+// The value as read from config rather than the registry's
+func (f *FloatFlag) GetFrom(config RemoteConfig) float64 {
+	value, _, _ := f.flags.valueIn(f.flag, config)
+	return value.(float64)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+type StringFlag struct {
+	flags *Flags
+	flag  *flag
+}
+
+// validate may be nil
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (f *Flags) String(key string, defaultValue string, description string, validate func(string) error) *StringFlag {
+	fl := &flag{key: key, flagType: StringType, description: description, defaultValue: defaultValue}
+	fl.parse = func(value string) (interface{}, error) {
+		if validate != nil {
+			if err := validate(value); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	f.register(fl)
+	return &StringFlag{flags: f, flag: fl}
+}
+
+func (f *StringFlag) Key() string {
+	return f.flag.key
+}
+
+func (f *StringFlag) Get() string {
+	value, _, _ := f.flags.value(f.flag)
+	return value.(string)
+}
+
+// The value as read from config rather than the registry's
+func (f *StringFlag) GetFrom(config RemoteConfig) string {
+	value, _, _ := f.flags.valueIn(f.flag, config)
+	return value.(string)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+type JsonFlag struct {
+	flags *Flags
+	flag  *flag
+}
+
+/*
+A flag holding a JSON document. The raw JSON is returned; a value that isn't valid JSON,
+or doesn't match schema when one is given, is rejected.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func (f *Flags) Json(key string, defaultValue string, description string, schema *logic.Schema) *JsonFlag {
+	fl := &flag{key: key, flagType: JsonType, description: description, defaultValue: defaultValue}
+	fl.parse = func(value string) (interface{}, error) {
+		var document interface{}
+		if err := json.Unmarshal([]byte(value), &document); err != nil {
+			return nil, errors.New("not valid JSON")
+		}
+		if schema != nil {
+			if err := schema.Check(document); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	f.register(fl)
+	return &JsonFlag{flags: f, flag: fl}
+}
+
+func (f *JsonFlag) Key() string {
+	return f.flag.key
+}
+
+func (f *JsonFlag) Get() string {
+	value, _, _ := f.flags.value(f.flag)
+	return value.(string)
+}
+
+// The value as read from config rather than the registry's
+func (f *JsonFlag) GetFrom(config RemoteConfig) string {
+	value, _, _ := f.flags.valueIn(f.flag, config)
+	return value.(string)
+}
+
+// Validation for Int flags: min <= value <= max
+// Only used w/in Go -- Ok to be skipped by gomobile
+func IntRange(min int, max int) func(int) error {
+	return func(value int) error {
+		if value < min || value > max {
+			return fmt.Errorf("%d is outside %d..%d", value, min, max)
+		}
+		return nil
+	}
+}
+
+// Validation for String flags: the value is one of choices
+// Only used w/in Go -- Ok to be skipped by gomobile
+func OneOf(choices ...string) func(string) error {
+	return func(value string) error {
+		for _, choice := range choices {
+			if value == choice {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %v", value, choices)
+	}
+}
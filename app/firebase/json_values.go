@@ -0,0 +1,126 @@
+package firebase
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/marcuswu/msgpack/app/logic"
+)
+
+// JsonValueError reports a GetJson value that couldn't be used
+type JsonValueError struct {
+	Key string
+	err error
+}
+
+func (e *JsonValueError) Error() string {
+	return fmt.Sprintf("remote config %s: %s", e.Key, e.err.Error())
+}
+
+func (e *JsonValueError) Unwrap() error {
+	return e.err
+}
+
+type jsonValue struct {
+	raw  string
+	data *logic.Field
+	err  error
+}
+
+/*
+JsonValues decodes GetJson values into logic.Field documents so consumers don't each
+parse the raw string. A key can be registered with a schema the value must match.
+Each value is parsed once and cached until its raw string changes or the cache is
+invalidated, which Container does on every activation.
+A value that is empty, malformed or doesn't match its schema is reported as an error
+rather than handed to the consumer.
+*/
+type JsonValues struct {
+	mu      sync.Mutex
+	config  func() RemoteConfig
+	schemas map[string]*logic.Schema
+	cache   map[string]*jsonValue
+}
+
+// config is called on every read so the values follow whichever RemoteConfig is current
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewJsonValues(config func() RemoteConfig) *JsonValues {
+	return &JsonValues{config: config, schemas: make(map[string]*logic.Schema), cache: make(map[string]*jsonValue)}
+}
+
+// Check the value of key against schema from now on
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (j *JsonValues) Register(key string, schema *logic.Schema) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.schemas[key] = schema
+	delete(j.cache, key)
+}
+
+/*
+Get returns the value of key as a document. The result is a copy the caller may change.
+Errors are a *JsonValueError wrapping the parse error or *logic.SchemaError.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func (j *JsonValues) Get(key string) (*logic.Field, error) {
+	entry := j.entry(key)
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return entry.data.Clone(), nil
+}
+
+// The value of key as a document, or nil when it can't be used (see Error)
+func (j *JsonValues) Field(key string) *logic.Field {
+	data, _ := j.Get(key)
+	return data
+}
+
+// Why the value of key can't be used, "" when it can
+func (j *JsonValues) Error(key string) string {
+	if err := j.entry(key).err; err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// Drop every cached value, e.g. after overrides change without the raw strings changing
+func (j *JsonValues) Invalidate() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.cache = make(map[string]*jsonValue)
+}
+
+func (j *JsonValues) entry(key string) *jsonValue {
+	raw := ""
+	if config := j.config(); config != nil {
+		raw = config.GetJson(key)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if entry, ok := j.cache[key]; ok && entry.raw == raw {
+		return entry
+	}
+	entry := &jsonValue{raw: raw}
+	entry.data, entry.err = parseJsonValue(key, raw, j.schemas[key])
+	j.cache[key] = entry
+	return entry
+}
+
+func parseJsonValue(key string, raw string, schema *logic.Schema) (*logic.Field, error) {
+	if raw == "" {
+		return nil, &JsonValueError{Key: key, err: fmt.Errorf("no value")}
+	}
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return nil, &JsonValueError{Key: key, err: err}
+	}
+	if schema != nil {
+		if err := schema.Check(value); err != nil {
+			return nil, &JsonValueError{Key: key, err: err}
+		}
+	}
+	return logic.NewFieldWithValue(key, value), nil
+}
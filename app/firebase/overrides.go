@@ -0,0 +1,208 @@
+package firebase
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/marcuswu/msgpack/app/files"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+/*
+Overrides is a RemoteConfig that lets values be forced locally, e.g. by QA on a test device,
+without touching the Firebase console. Keys without an override read through to the
+wrapped config. Overrides are kept as strings, the way remote config delivers values,
+and persisted to a file once one is set with Load.
+*/
+type Overrides struct {
+	mu       sync.Mutex
+	config   RemoteConfig
+	values   map[string]string
+	filename string
+	// Run after each successful FetchAndActivate
+	activated []func()
+}
+
+// config may be nil, in which case only overridden keys have values
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewOverrides(config RemoteConfig) *Overrides {
+	return &Overrides{config: config, values: make(map[string]string)}
+}
+
+// Read overrides saved in filename, keeping them there from now on. A missing file means no overrides.
+func (o *Overrides) Load(filename string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.filename = filename
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	values := make(map[string]string)
+	if err := msgpack.Unmarshal(data, &values); err != nil {
+		return fmt.Errorf("could not read config overrides: %w", err)
+	}
+	o.values = values
+	return nil
+}
+
+func (o *Overrides) save() error {
+	if o.filename == "" {
+		return nil
+	}
+	data, err := msgpack.Marshal(o.values)
+	if err != nil {
+		return err
+	}
+	return files.WriteAtomic(o.filename, data, 0)
+}
+
+// Force key to value until it is removed
+func (o *Overrides) Set(key string, value string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.values[key] = value
+	return o.save()
+}
+
+func (o *Overrides) Remove(key string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	delete(o.values, key)
+	return o.save()
+}
+
+func (o *Overrides) Clear() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.values = make(map[string]string)
+	return o.save()
+}
+
+func (o *Overrides) Has(key string) bool {
+	_, ok := o.override(key)
+	return ok
+}
+
+// The overridden keys in order
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (o *Overrides) Keys() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	keys := make([]string, 0, len(o.values))
+	for key := range o.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (o *Overrides) override(key string) (string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	value, ok := o.values[key]
+	return value, ok
+}
+
+func (o *Overrides) FetchAndActivate(callback *ActivateCallback) {
+	if o.config == nil {
+		callback.OnActivate(false)
+		return
+	}
+	o.config.FetchAndActivate(&ActivateCallback{Callback: func(activated bool) {
+		if activated {
+			o.mu.Lock()
+			hooks := append([]func(){}, o.activated...)
+			o.mu.Unlock()
+			for _, hook := range hooks {
+				hook()
+			}
+		}
+		callback.OnActivate(activated)
+	}})
+}
+
+// Run hook after each successful FetchAndActivate, before its callback, e.g. to drop values parsed from the previous activation
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (o *Overrides) OnActivate(hook func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.activated = append(o.activated, hook)
+}
+
+func (o *Overrides) GetBool(key string) bool {
+	if value, ok := o.override(key); ok {
+		b, _ := strconv.ParseBool(value)
+		return b
+	}
+	if o.config == nil {
+		return false
+	}
+	return o.config.GetBool(key)
+}
+
+func (o *Overrides) GetFloat64(key string) float64 {
+	if value, ok := o.override(key); ok {
+		f, _ := strconv.ParseFloat(value, 64)
+		return f
+	}
+	if o.config == nil {
+		return 0
+	}
+	return o.config.GetFloat64(key)
+}
+
+func (o *Overrides) GetInt(key string) int {
+	if value, ok := o.override(key); ok {
+		i, _ := strconv.Atoi(value)
+		return i
+	}
+	if o.config == nil {
+		return 0
+	}
+	return o.config.GetInt(key)
+}
+
+func (o *Overrides) GetStr(key string) string {
+	if value, ok := o.override(key); ok {
+		return value
+	}
+	if o.config == nil {
+		return ""
+	}
+	return o.config.GetStr(key)
+}
+
+func (o *Overrides) GetJson(key string) string {
+	if value, ok := o.override(key); ok {
+		return value
+	}
+	if o.config == nil {
+		return ""
+	}
+	return o.config.GetJson(key)
+}
+
+// Implements ValueSource
+func (o *Overrides) GetSource(key string) int {
+	if _, ok := o.override(key); ok {
+		return SourceOverride
+	}
+	if o.config == nil {
+		return SourceDefault
+	}
+	if sourced, ok := o.config.(ValueSource); ok {
+		return sourced.GetSource(key)
+	}
+	if o.config.GetStr(key) != "" {
+		return SourceRemote
+	}
+	return SourceDefault
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"github.com/marcuswu/msgpack/app/firebase"
+)
+
+// Feature flags read from remote config. Declare new flags here rather than reading raw keys.
+var flags = firebase.NewFlags(Config)
+
+var (
+	BackupCount = flags.Int("backup_count", 3,
+		"Previous versions of a file kept next to it on save", firebase.IntRange(0, 10))
+	MaxLoadedDocuments = flags.Int("max_loaded_documents", 5,
+		"Open documents kept decoded in memory before the least recently shown are evicted", firebase.IntRange(1, 50))
+	MaxVersions = flags.Int("max_versions", 50,
+		"Saved versions kept in a file's local history", firebase.IntRange(1, 1000))
+)
+
+// The flag registry, e.g. for listing flags on a debug screen
+func Flags() *firebase.Flags {
+	return flags
+}
+
+// Parsed GetJson values of the Default container; register a schema for a key before reading it
+func JsonValues() *firebase.JsonValues {
+	return defaultContainer.JsonValues()
+}
@@ -0,0 +1,517 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	MsgPackCodec = "msgpack"
+	JsonCodec    = "json"
+	YamlCodec    = "yaml"
+)
+
+/*
+DecodeError records where a codec gave up on a file.
+msgpack reports a byte offset, JSON and YAML report a line and column (1 based).
+Offset is also filled in for JSON, and for YAML when the line could be found.
+Fields that a codec can't provide are -1.
+*/
+type DecodeError struct {
+	Codec  string
+	Offset int
+	Line   int
+	Column int
+	msg    string
+	err    error
+}
+
+func (e *DecodeError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%s: line %d, column %d: %s", e.Codec, e.Line, e.Column, e.msg)
+	case e.Line > 0:
+		return fmt.Sprintf("%s: line %d: %s", e.Codec, e.Line, e.msg)
+	case e.Offset >= 0:
+		return fmt.Sprintf("%s: offset %d: %s", e.Codec, e.Offset, e.msg)
+	}
+	return fmt.Sprintf("%s: %s", e.Codec, e.msg)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.err
+}
+
+func (e *DecodeError) Message() string {
+	return e.msg
+}
+
+// DecodeReport collects the failure of every codec tried on a file
+type DecodeReport struct {
+	errors []*DecodeError
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewDecodeReport(errs ...*DecodeError) *DecodeReport {
+	return &DecodeReport{errors: errs}
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (r *DecodeReport) Add(err *DecodeError) {
+	r.errors = append(r.errors, err)
+}
+
+func (r *DecodeReport) Size() int {
+	return len(r.errors)
+}
+
+func (r *DecodeReport) Get(i int) *DecodeError {
+	if i < 0 || i >= len(r.errors) {
+		return nil
+	}
+	return r.errors[i]
+}
+
+// Get the error reported by a given codec, or nil if that codec wasn't tried
+func (r *DecodeReport) ForCodec(codec string) *DecodeError {
+	for _, err := range r.errors {
+		if err.Codec == codec {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *DecodeReport) Error() string {
+	msgs := make([]string, 0, len(r.errors))
+	for _, err := range r.errors {
+		msgs = append(msgs, err.Error())
+	}
+	return "could not decode file: " + strings.Join(msgs, "; ")
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (r *DecodeReport) Unwrap() []error {
+	errs := make([]error, 0, len(r.errors))
+	for _, err := range r.errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// Guess the codec of a file from its first bytes. Only maps and arrays are recognised as msgpack.
+func DetectCodec(prefix []byte) string {
+	trimmed := bytes.TrimLeft(prefix, " \t\r\n")
+	if len(trimmed) == 0 {
+		return ""
+	}
+	c := trimmed[0]
+	switch {
+	case len(trimmed) == len(prefix) && (msgpcode.IsFixedMap(c) || c == msgpcode.Map16 || c == msgpcode.Map32 ||
+		msgpcode.IsFixedArray(c) || c == msgpcode.Array16 || c == msgpcode.Array32):
+		return MsgPackCodec
+	case c == '{' || c == '[':
+		return JsonCodec
+	}
+	return YamlCodec
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func DecodeMsgPack(fileData []byte) (*Field, error) {
+	data := make(map[string]interface{})
+	reader := bytes.NewReader(fileData)
+	err := msgpack.NewDecoder(reader).Decode(&data)
+	if err != nil {
+		return nil, msgPackDecodeError(fileData, reader, err)
+	}
+	return NewFieldWithValue("", data), nil
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func DecodeJson(fileData []byte) (*Field, error) {
+	data := make(map[string]interface{})
+	err := json.Unmarshal(fileData, &data)
+	if err != nil {
+		return nil, jsonDecodeError(fileData, err)
+	}
+	return NewFieldWithValue("", data), nil
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func DecodeYaml(fileData []byte) (*Field, error) {
+	data := make(map[string]interface{})
+	err := yaml.Unmarshal(fileData, &data)
+	if err != nil {
+		return nil, yamlDecodeError(fileData, err)
+	}
+	return NewFieldWithValue("", data), nil
+}
+
+/*
+DecodeAny decodes fileData with codec whatever its root is: a map, an array or a lone value.
+Values get the types the codec itself decodes to, e.g. float64 for JSON numbers.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func DecodeAny(codec string, fileData []byte) (*Field, error) {
+	var data interface{}
+	switch codec {
+	case MsgPackCodec:
+		reader := bytes.NewReader(fileData)
+		err := msgpack.NewDecoder(reader).Decode(&data)
+		if err == nil && reader.Len() > 0 {
+			err = errMsgPackTrailingData
+		}
+		if err != nil {
+			return nil, msgPackDecodeError(fileData, reader, err)
+		}
+	case JsonCodec:
+		if err := json.Unmarshal(fileData, &data); err != nil {
+			return nil, jsonDecodeError(fileData, err)
+		}
+	case YamlCodec:
+		if err := yaml.Unmarshal(fileData, &data); err != nil {
+			return nil, yamlDecodeError(fileData, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown codec %s", codec)
+	}
+	return NewFieldWithValue("", data), nil
+}
+
+func msgPackDecodeError(fileData []byte, reader *bytes.Reader, err error) *DecodeError {
+	msg := strings.TrimPrefix(err.Error(), "msgpack: ")
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		msg = "unexpected end of data"
+	}
+	return &DecodeError{
+		Codec:  MsgPackCodec,
+		Offset: len(fileData) - reader.Len(),
+		Line:   -1,
+		Column: -1,
+		msg:    msg,
+		err:    err,
+	}
+}
+
+func jsonDecodeError(fileData []byte, err error) *DecodeError {
+	offset := -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// Offset is just past the offending byte
+		offset = int(syntaxErr.Offset) - 1
+		if offset < 0 {
+			offset = 0
+		}
+	case errors.As(err, &typeErr):
+		offset = int(typeErr.Offset)
+	}
+	line, column := -1, -1
+	if offset >= 0 {
+		line, column = lineColumn(fileData, offset)
+	}
+	return &DecodeError{
+		Codec:  JsonCodec,
+		Offset: offset,
+		Line:   line,
+		Column: column,
+		msg:    strings.TrimPrefix(err.Error(), "json: "),
+		err:    err,
+	}
+}
+
+var yamlLineRegex = regexp.MustCompile(`line (\d+)(?:, column (\d+))?: `)
+
+func yamlDecodeError(fileData []byte, err error) *DecodeError {
+	msg := strings.TrimPrefix(err.Error(), "yaml: ")
+	line, column, offset := -1, -1, -1
+	if match := yamlLineRegex.FindStringSubmatchIndex(msg); match != nil {
+		line, _ = strconv.Atoi(msg[match[2]:match[3]])
+		if match[4] >= 0 {
+			column, _ = strconv.Atoi(msg[match[4]:match[5]])
+		}
+		offset = lineOffset(fileData, line)
+		msg = msg[:match[0]] + msg[match[1]:]
+	}
+	return &DecodeError{
+		Codec:  YamlCodec,
+		Offset: offset,
+		Line:   line,
+		Column: column,
+		msg:    msg,
+		err:    err,
+	}
+}
+
+// Convert a byte offset to a 1 based line and column
+func lineColumn(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := offset - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// Byte offset of the start of a 1 based line, or -1 if there is no such line
+func lineOffset(data []byte, line int) int {
+	offset := 0
+	for l := 1; l < line; l++ {
+		next := bytes.IndexByte(data[offset:], '\n')
+		if next < 0 {
+			return -1
+		}
+		offset += next + 1
+	}
+	return offset
+}
+
+/*
+Salvage is the result of decoding a damaged file as far as possible.
+Data holds everything decoded before the corruption, Offset is where decoding stopped
+and Tail is the unparsable remainder of the file.
+*/
+type Salvage struct {
+	Codec  string
+	Data   *Field
+	Offset int
+	Error  *DecodeError
+	tail   []byte
+}
+
+func (s *Salvage) Tail() []byte {
+	return s.tail
+}
+
+// Hex view of the unparsable tail, limited to maxBytes (all of it if maxBytes <= 0)
+func (s *Salvage) TailHex(maxBytes int) string {
+	tail := s.tail
+	if maxBytes > 0 && len(tail) > maxBytes {
+		tail = tail[:maxBytes]
+	}
+	return HexDump(tail, s.Offset)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func SalvageMsgPack(fileData []byte) *Salvage {
+	reader := bytes.NewReader(fileData)
+	dec := msgpack.NewDecoder(reader)
+	value, err := salvageMsgPackValue(dec)
+	if err == nil && reader.Len() > 0 {
+		// A value decoded from the first bytes of text (e.g. '{' is a fixint) doesn't make the rest of the file msgpack
+		err = errMsgPackTrailingData
+	}
+	result := &Salvage{Codec: MsgPackCodec, Data: NewFieldWithValue("", value), Offset: len(fileData)}
+	if err != nil {
+		result.Error = msgPackDecodeError(fileData, reader, err)
+		result.Offset = result.Error.Offset
+		result.tail = fileData[result.Offset:]
+	}
+	return result
+}
+
+var errMsgPackTrailingData = errors.New("msgpack: unexpected data after the end of the document")
+
+// Whether the salvaged root is a map or array rather than a lone value
+func (s *Salvage) HasContainerRoot() bool {
+	if s.Data == nil {
+		return false
+	}
+	rootType := TypeOf(s.Data.Value())
+	return rootType == MapType || rootType == ArrayType
+}
+
+// Decode a msgpack value, keeping whatever entries of maps and arrays were read before a failure
+func salvageMsgPackValue(dec *msgpack.Decoder) (interface{}, error) {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case msgpcode.IsFixedMap(code) || code == msgpcode.Map16 || code == msgpcode.Map32:
+		size, err := dec.DecodeMapLen()
+		if err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{})
+		for i := 0; i < size; i++ {
+			key, err := dec.DecodeString()
+			if err != nil {
+				return m, err
+			}
+			value, err := salvageMsgPackValue(dec)
+			if err != nil {
+				if value != nil {
+					m[key] = value
+				}
+				return m, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		size, err := dec.DecodeArrayLen()
+		if err != nil {
+			return nil, err
+		}
+		a := make([]interface{}, 0)
+		for i := 0; i < size; i++ {
+			value, err := salvageMsgPackValue(dec)
+			if err != nil {
+				if value != nil {
+					a = append(a, value)
+				}
+				return a, err
+			}
+			a = append(a, value)
+		}
+		return a, nil
+	}
+	return dec.DecodeInterface()
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func SalvageJson(fileData []byte) *Salvage {
+	dec := json.NewDecoder(bytes.NewReader(fileData))
+	dec.UseNumber()
+	value, err := salvageJsonValue(dec)
+	result := &Salvage{Codec: JsonCodec, Data: NewFieldWithValue("", value), Offset: len(fileData)}
+	if err != nil {
+		offset := int(dec.InputOffset())
+		result.Error = jsonDecodeError(fileData, err)
+		if result.Error.Offset < 0 {
+			result.Error.Offset = offset
+			result.Error.Line, result.Error.Column = lineColumn(fileData, offset)
+		}
+		result.Offset = result.Error.Offset
+		result.tail = fileData[result.Offset:]
+	}
+	return result
+}
+
+func salvageJsonValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			m := make(map[string]interface{})
+			for dec.More() {
+				keyToken, err := dec.Token()
+				if err != nil {
+					return m, err
+				}
+				key, _ := keyToken.(string)
+				value, err := salvageJsonValue(dec)
+				if err != nil {
+					if value != nil {
+						m[key] = value
+					}
+					return m, err
+				}
+				m[key] = value
+			}
+			_, err := dec.Token()
+			return m, err
+		case '[':
+			a := make([]interface{}, 0)
+			for dec.More() {
+				value, err := salvageJsonValue(dec)
+				if err != nil {
+					if value != nil {
+						a = append(a, value)
+					}
+					return a, err
+				}
+				a = append(a, value)
+			}
+			_, err := dec.Token()
+			return a, err
+		}
+		return nil, fmt.Errorf("unexpected delimiter %s", t)
+	case json.Number:
+		// Match json.Unmarshal, which decodes every number as a float64
+		f, err := t.Float64()
+		return f, err
+	}
+	return token, nil
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func SalvageYaml(fileData []byte) *Salvage {
+	data, err := DecodeYaml(fileData)
+	if err == nil {
+		return &Salvage{Codec: YamlCodec, Data: data, Offset: len(fileData)}
+	}
+	decodeErr := err.(*DecodeError)
+	result := &Salvage{Codec: YamlCodec, Data: NewFieldWithValue("", nil), Offset: 0, Error: decodeErr, tail: fileData}
+
+	// YAML has no streaming decoder that can stop mid-document, so keep the longest run
+	// of complete lines before the failure that still parses
+	end := decodeErr.Offset
+	if end < 0 {
+		end = len(fileData)
+	}
+	for end > 0 {
+		end = bytes.LastIndexByte(fileData[:end], '\n') + 1
+		if partial, err := DecodeYaml(fileData[:end]); err == nil {
+			result.Data = partial
+			result.Offset = end
+			result.tail = fileData[end:]
+			break
+		}
+		if end == 0 {
+			break
+		}
+		end--
+	}
+	return result
+}
+
+// Hex dump in the style of hexdump -C with offsets starting from base
+func HexDump(data []byte, base int) string {
+	var sb strings.Builder
+	for start := 0; start < len(data); start += 16 {
+		end := start + 16
+		if end > len(data) {
+			end = len(data)
+		}
+		row := data[start:end]
+		fmt.Fprintf(&sb, "%08x  ", base+start)
+		for i := 0; i < 16; i++ {
+			if i < len(row) {
+				fmt.Fprintf(&sb, "%02x ", row[i])
+			} else {
+				sb.WriteString("   ")
+			}
+			if i == 7 {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString(" |")
+		for _, b := range row {
+			if b >= 0x20 && b < 0x7f {
+				sb.WriteByte(b)
+			} else {
+				sb.WriteByte('.')
+			}
+		}
+		sb.WriteString("|\n")
+	}
+	return sb.String()
+}
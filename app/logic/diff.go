@@ -0,0 +1,150 @@
+package logic
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Kinds of Change
+const (
+	ChangeAdded = iota
+	ChangeRemoved
+	ChangeModified
+)
+
+func ChangeKindString(kind int) string {
+	switch kind {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+/*
+Change is one difference between two documents.
+Old is nil for ChangeAdded and New is nil for ChangeRemoved.
+*/
+type Change struct {
+	Path string
+	Kind int
+	Old  *Field
+	New  *Field
+}
+
+// Diff lists the changes between two documents in path order
+type Diff struct {
+	changes []*Change
+}
+
+func (d *Diff) Size() int {
+	return len(d.changes)
+}
+
+func (d *Diff) Get(i int) *Change {
+	if i < 0 || i >= len(d.changes) {
+		return nil
+	}
+	return d.changes[i]
+}
+
+// Number of changes of a kind
+func (d *Diff) CountOf(kind int) int {
+	count := 0
+	for _, change := range d.changes {
+		if change.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+/*
+Compare finds what changed from old to new.
+Maps are compared key by key and arrays index by index, so an insertion in the middle
+of an array shows up as changes to every later index. A value whose type changed is
+reported as modified rather than compared further.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func Compare(old interface{}, new interface{}) *Diff {
+	diff := &Diff{}
+	compare(old, new, []string{}, diff)
+	return diff
+}
+
+func (d *Diff) add(kind int, path []string, old interface{}, new interface{}) {
+	key := ""
+	if len(path) > 0 {
+		key = path[len(path)-1]
+	}
+	change := &Change{Path: strings.Join(path, "/"), Kind: kind}
+	if kind != ChangeAdded {
+		change.Old = NewFieldWithValue(key, cloneValue(old))
+	}
+	if kind != ChangeRemoved {
+		change.New = NewFieldWithValue(key, cloneValue(new))
+	}
+	d.changes = append(d.changes, change)
+}
+
+func compare(old interface{}, new interface{}, path []string, diff *Diff) {
+	if reflect.DeepEqual(old, new) {
+		return
+	}
+	child := func(segment string) []string {
+		return append(append([]string{}, path...), segment)
+	}
+
+	switch o := old.(type) {
+	case map[string]interface{}:
+		n, ok := new.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(o)+len(n))
+		for key := range o {
+			keys = append(keys, key)
+		}
+		for key := range n {
+			if _, ok := o[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			oldValue, inOld := o[key]
+			newValue, inNew := n[key]
+			switch {
+			case inOld && inNew:
+				compare(oldValue, newValue, child(key), diff)
+			case inOld:
+				diff.add(ChangeRemoved, child(key), oldValue, nil)
+			default:
+				diff.add(ChangeAdded, child(key), nil, newValue)
+			}
+		}
+		return
+	case []interface{}:
+		n, ok := new.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(o) || i < len(n); i++ {
+			switch {
+			case i < len(o) && i < len(n):
+				compare(o[i], n[i], child(strconv.Itoa(i)), diff)
+			case i < len(o):
+				diff.add(ChangeRemoved, child(strconv.Itoa(i)), o[i], nil)
+			default:
+				diff.add(ChangeAdded, child(strconv.Itoa(i)), nil, n[i])
+			}
+		}
+		return
+	}
+	diff.add(ChangeModified, path, old, new)
+}
@@ -0,0 +1,34 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func EncodeMsgPack(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	err := enc.Encode(value)
+	return buf.Bytes(), err
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func EncodeJson(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	err := enc.Encode(value)
+	return buf.Bytes(), err
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func EncodeYaml(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	err := enc.Encode(value)
+	return buf.Bytes(), err
+}
@@ -0,0 +1,179 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type ErrorKind int
+
+const (
+	UnknownError ErrorKind = iota
+	NotFoundError
+	OutOfBoundsError
+	InvalidIndexError
+	NotContainerError
+	TypeMismatchError
+)
+
+func ErrorKindString(k ErrorKind) string {
+	switch k {
+	case NotFoundError:
+		return "NotFoundError"
+	case OutOfBoundsError:
+		return "OutOfBoundsError"
+	case InvalidIndexError:
+		return "InvalidIndexError"
+	case NotContainerError:
+		return "NotContainerError"
+	case TypeMismatchError:
+		return "TypeMismatchError"
+	}
+	return "UnknownError"
+}
+
+/*
+FieldError describes a failure to resolve, read or write a value in a document.
+Go callers can use errors.As to inspect it. gomobile cannot bind the named
+ErrorKind / FieldType values, so the platform reads them via Code(),
+ExpectedType() and ActualType() instead.
+*/
+type FieldError struct {
+	kind     ErrorKind
+	path     []string
+	segment  string
+	expected FieldType
+	actual   FieldType
+	msg      string
+	err      error
+}
+
+func (e *FieldError) Error() string {
+	return e.msg
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.err
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (e *FieldError) Kind() ErrorKind {
+	return e.kind
+}
+
+func (e *FieldError) Code() int {
+	return int(e.kind)
+}
+
+func (e *FieldError) Message() string {
+	return e.msg
+}
+
+// Path of the value that failed, joined with "/"
+func (e *FieldError) Path() string {
+	return strings.Join(e.path, "/")
+}
+
+// The path segment that could not be resolved
+func (e *FieldError) Segment() string {
+	return e.segment
+}
+
+func (e *FieldError) ExpectedType() int {
+	return int(e.expected)
+}
+
+func (e *FieldError) ActualType() int {
+	return int(e.actual)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (e *FieldError) Is(target error) bool {
+	t, ok := target.(*FieldError)
+	if !ok {
+		return false
+	}
+	return t.kind == e.kind
+}
+
+// Sentinels for use with errors.Is, e.g. errors.Is(err, logic.ErrNotFound)
+var (
+	ErrNotFound     = &FieldError{kind: NotFoundError, msg: "not found"}
+	ErrOutOfBounds  = &FieldError{kind: OutOfBoundsError, msg: "index out of bounds"}
+	ErrInvalidIndex = &FieldError{kind: InvalidIndexError, msg: "invalid index"}
+	ErrNotContainer = &FieldError{kind: NotContainerError, msg: "not a map or an array"}
+	ErrTypeMismatch = &FieldError{kind: TypeMismatchError, msg: "type mismatch"}
+)
+
+// ErrorCode returns the ErrorKind of err as an int, or UnknownError for errors that are not a FieldError
+func ErrorCode(err error) int {
+	var fe *FieldError
+	if errors.As(err, &fe) {
+		return fe.Code()
+	}
+	return int(UnknownError)
+}
+
+func pathPrefix(path []string, idx int) []string {
+	if idx+1 > len(path) {
+		idx = len(path) - 1
+	}
+	return append([]string{}, path[:idx+1]...)
+}
+
+func notFoundError(path []string, idx int) *FieldError {
+	return &FieldError{
+		kind:    NotFoundError,
+		path:    pathPrefix(path, idx),
+		segment: path[idx],
+		msg:     fmt.Sprintf("unknown field %s", path[idx]),
+	}
+}
+
+func outOfBoundsError(path []string, idx int, index int, length int) *FieldError {
+	return &FieldError{
+		kind:    OutOfBoundsError,
+		path:    pathPrefix(path, idx),
+		segment: path[idx],
+		msg:     fmt.Sprintf("index out of bounds %d; len: %d", index, length),
+	}
+}
+
+func invalidIndexError(path []string, idx int, err error) *FieldError {
+	return &FieldError{
+		kind:    InvalidIndexError,
+		path:    pathPrefix(path, idx),
+		segment: path[idx],
+		msg:     fmt.Sprintf("invalid array index %s", path[idx]),
+		err:     err,
+	}
+}
+
+func notContainerError(path []string, idx int, value interface{}) *FieldError {
+	segment := ""
+	if idx >= 0 && idx < len(path) {
+		segment = path[idx]
+	}
+	p := []string{}
+	if idx >= 0 {
+		p = pathPrefix(path, idx)
+	}
+	return &FieldError{
+		kind:    NotContainerError,
+		path:    p,
+		segment: segment,
+		actual:  TypeOf(value),
+		msg:     fmt.Sprintf("field %s is not a map or an array", segment),
+	}
+}
+
+func typeMismatchError(method string, expected FieldType, value interface{}) *FieldError {
+	actual := TypeOf(value)
+	return &FieldError{
+		kind:     TypeMismatchError,
+		expected: expected,
+		actual:   actual,
+		msg:      fmt.Sprintf("%s() called on a %s value (expected %s)", method, TypeString(actual), TypeString(expected)),
+	}
+}
@@ -1,9 +1,7 @@
 package logic
 
 import (
-	"errors"
 	"fmt"
-	"reflect"
 	"time"
 )
 
@@ -145,7 +143,7 @@ func (f *Field) GetInt() (int, error) {
 	uval, uok := f.value.(uint)
 	var err error = nil
 	if !ok && !uok {
-		err = errors.New("GetInt() called on a non-int value")
+		err = typeMismatchError("GetInt", IntType, f.value)
 	}
 	if uok {
 		return int(uval), err
@@ -165,7 +163,7 @@ func (f *Field) GetInt8() (int8, error) {
 	uval, uok := f.value.(uint8)
 	var err error = nil
 	if !ok && !uok {
-		err = errors.New("GetInt8() called on a non-int8 value")
+		err = typeMismatchError("GetInt8", Int8Type, f.value)
 	}
 	if uok {
 		return int8(uval), err
@@ -185,7 +183,7 @@ func (f *Field) GetInt16() (int16, error) {
 	uval, uok := f.value.(uint16)
 	var err error = nil
 	if !ok && !uok {
-		err = fmt.Errorf("GetInt16() called on a non-int16 (%s) value", reflect.TypeOf(f.value))
+		err = typeMismatchError("GetInt16", Int16Type, f.value)
 	}
 	if uok {
 		return int16(uval), err
@@ -205,7 +203,7 @@ func (f *Field) GetInt32() (int32, error) {
 	uval, uok := f.value.(uint32)
 	var err error = nil
 	if !ok && !uok {
-		err = errors.New("GetInt32() called on a non-int32 value")
+		err = typeMismatchError("GetInt32", Int32Type, f.value)
 	}
 	if uok {
 		return int32(uval), err
@@ -225,7 +223,7 @@ func (f *Field) GetInt64() (int64, error) {
 	uval, uok := f.value.(uint64)
 	var err error = nil
 	if !ok && !uok {
-		err = errors.New("GetInt64() called on a non-int64 value")
+		err = typeMismatchError("GetInt64", Int64Type, f.value)
 	}
 	if uok {
 		return int64(uval), err
@@ -244,7 +242,7 @@ func (f *Field) GetBool() (bool, error) {
 	val, ok := f.value.(bool)
 	var err error = nil
 	if !ok {
-		err = errors.New("GetBool() called on a non-bool value")
+		err = typeMismatchError("GetBool", BoolType, f.value)
 	}
 	return val, err
 }
@@ -257,7 +255,7 @@ func (f *Field) GetString() (string, error) {
 	val, ok := f.value.(string)
 	var err error = nil
 	if !ok {
-		err = errors.New("GetString() called on a non-string value")
+		err = typeMismatchError("GetString", StringType, f.value)
 	}
 	return val, err
 }
@@ -270,7 +268,7 @@ func (f *Field) GetFloat32() (float32, error) {
 	val, ok := f.value.(float32)
 	var err error = nil
 	if !ok {
-		err = errors.New("GetFloat32() called on a non-float32 value")
+		err = typeMismatchError("GetFloat32", Float32Type, f.value)
 	}
 	return val, err
 }
@@ -283,7 +281,7 @@ func (f *Field) GetFloat64() (float64, error) {
 	val, ok := f.value.(float64)
 	var err error = nil
 	if !ok {
-		err = errors.New("GetFloat64() called on a non-float64 value")
+		err = typeMismatchError("GetFloat64", Float64Type, f.value)
 	}
 	return val, err
 }
@@ -297,7 +295,7 @@ func (f *Field) GetTime() (int64, error) {
 	if ok {
 		return val.UnixMilli(), nil
 	}
-	return 0, errors.New("GetFloat64() called on a non-float64 value")
+	return 0, typeMismatchError("GetTime", TimeType, f.value)
 }
 
 func (f *Field) SetTime(v int64) {
@@ -307,7 +305,7 @@ func (f *Field) SetTime(v int64) {
 func (f *Field) GetMap() (*Map, error) {
 	val, ok := f.value.(map[string]interface{})
 	if !ok {
-		return nil, errors.New("GetMap() called on a non-map value")
+		return nil, typeMismatchError("GetMap", MapType, f.value)
 	}
 	return NewMap(val), nil
 }
@@ -319,7 +317,7 @@ func (f *Field) SetMap(m *Map) {
 func (f *Field) GetArray() (*Array, error) {
 	val, ok := f.value.([]interface{})
 	if !ok {
-		return nil, errors.New("GetArray() called on a non-array value")
+		return nil, typeMismatchError("GetArray", ArrayType, f.value)
 	}
 	return NewArray(val), nil
 }
@@ -0,0 +1,354 @@
+package logic
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+HexToken is one msgpack encoded item in a buffer.
+Offset and Length cover the format byte plus any length prefix and payload that
+belongs to the item itself. Size covers the whole encoded value, so for maps and
+arrays it includes every child.
+Path is the logical path of the value (see Map.GetPath), and map keys are reported
+as separate tokens with IsKey set and the path of the value they name.
+*/
+type HexToken struct {
+	Offset int
+	Length int
+	Size   int
+	Code   int
+	Family string
+	Value  string
+	Path   string
+	Depth  int
+	IsKey  bool
+}
+
+// Inspection holds the tokens of a msgpack buffer in the order they appear
+type Inspection struct {
+	data   []byte
+	tokens []*HexToken
+	values map[string]int
+}
+
+func (in *Inspection) Size() int {
+	return len(in.tokens)
+}
+
+func (in *Inspection) Get(i int) *HexToken {
+	if i < 0 || i >= len(in.tokens) {
+		return nil
+	}
+	return in.tokens[i]
+}
+
+func (in *Inspection) Data() []byte {
+	return in.data
+}
+
+// Index of the token for the value at path, or -1
+func (in *Inspection) IndexForPath(path string) int {
+	if i, ok := in.values[path]; ok {
+		return i
+	}
+	return -1
+}
+
+// Index of the innermost token whose bytes contain offset, or -1
+func (in *Inspection) IndexAtOffset(offset int) int {
+	// tokens are sorted by offset and never overlap
+	i := sort.Search(len(in.tokens), func(i int) bool {
+		return in.tokens[i].Offset > offset
+	}) - 1
+	if i < 0 || offset >= in.tokens[i].Offset+in.tokens[i].Length {
+		return -1
+	}
+	return i
+}
+
+// Path of the value at offset. Offsets inside a map key resolve to the value the key names.
+func (in *Inspection) PathAtOffset(offset int) (string, error) {
+	i := in.IndexAtOffset(offset)
+	if i < 0 {
+		return "", fmt.Errorf("offset %d is outside of any value", offset)
+	}
+	return in.tokens[i].Path, nil
+}
+
+// Hex dump of the bytes of token i
+func (in *Inspection) HexDump(i int) string {
+	token := in.Get(i)
+	if token == nil {
+		return ""
+	}
+	return HexDump(in.data[token.Offset:token.Offset+token.Length], token.Offset)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (in *Inspection) Tokens() []*HexToken {
+	return in.tokens
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func InspectMsgPack(data []byte) (*Inspection, error) {
+	in := &Inspection{data: data, values: make(map[string]int)}
+	w := &msgPackWalker{data: data, in: in}
+	end, err := w.value(0, []string{}, 0, false)
+	if err != nil {
+		return in, err
+	}
+	if end != len(data) {
+		return in, &DecodeError{Codec: MsgPackCodec, Offset: end, Line: -1, Column: -1, msg: fmt.Sprintf("%d trailing bytes", len(data)-end)}
+	}
+	return in, nil
+}
+
+type msgPackWalker struct {
+	data []byte
+	in   *Inspection
+}
+
+func (w *msgPackWalker) errorAt(offset int, format string, args ...interface{}) error {
+	return &DecodeError{Codec: MsgPackCodec, Offset: offset, Line: -1, Column: -1, msg: fmt.Sprintf(format, args...)}
+}
+
+/*
+size as an int once it fits in the bytes after the header at offset. Lengths and counts
+come from the data, so they are checked before converting: on 32-bit platforms a corrupt
+length can wrap negative as an int. Every item takes at least a byte, so counts are
+bounded the same way.
+*/
+func (w *msgPackWalker) fits(offset int, header int, size uint64, family string) (int, error) {
+	remaining := len(w.data) - offset - header
+	if remaining < 0 || size > uint64(remaining) {
+		return 0, w.errorAt(offset, "unexpected end of data in %s", family)
+	}
+	return int(size), nil
+}
+
+// Read an n byte big endian unsigned length or value following the format byte
+func (w *msgPackWalker) uint(offset int, n int) (uint64, error) {
+	if offset+1+n > len(w.data) {
+		return 0, w.errorAt(offset, "unexpected end of data")
+	}
+	b := w.data[offset+1 : offset+1+n]
+	switch n {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (w *msgPackWalker) add(token *HexToken) int {
+	w.in.tokens = append(w.in.tokens, token)
+	return len(w.in.tokens) - 1
+}
+
+// Walk the value (or map key) at offset and return the offset just past it
+func (w *msgPackWalker) value(offset int, path []string, depth int, isKey bool) (int, error) {
+	if offset >= len(w.data) {
+		return offset, w.errorAt(offset, "unexpected end of data")
+	}
+	c := w.data[offset]
+	pathStr := strings.Join(path, "/")
+	token := &HexToken{Offset: offset, Code: int(c), Path: pathStr, Depth: depth, IsKey: isKey}
+
+	header, count, isMap, isArray := 0, 0, false, false
+	payload := 0
+	switch {
+	case c <= 0x7f:
+		token.Family, token.Value, header = "positive fixint", fmt.Sprint(c), 1
+	case c >= 0xe0:
+		token.Family, token.Value, header = "negative fixint", fmt.Sprint(int8(c)), 1
+	case c >= 0x80 && c <= 0x8f:
+		token.Family, header, count, isMap = "fixmap", 1, int(c&0x0f), true
+	case c >= 0x90 && c <= 0x9f:
+		token.Family, header, count, isArray = "fixarray", 1, int(c&0x0f), true
+	case c >= 0xa0 && c <= 0xbf:
+		token.Family, header, payload = "fixstr", 1, int(c&0x1f)
+	case c == 0xc0:
+		token.Family, token.Value, header = "nil", "nil", 1
+	case c == 0xc2:
+		token.Family, token.Value, header = "false", "false", 1
+	case c == 0xc3:
+		token.Family, token.Value, header = "true", "true", 1
+	case c == 0xc4, c == 0xc5, c == 0xc6:
+		n := 1 << (c - 0xc4)
+		size, err := w.uint(offset, n)
+		if err != nil {
+			return offset, err
+		}
+		token.Family, header = fmt.Sprintf("bin%d", n*8), 1+n
+		if payload, err = w.fits(offset, header, size, token.Family); err != nil {
+			return offset, err
+		}
+	case c == 0xc7, c == 0xc8, c == 0xc9:
+		n := 1 << (c - 0xc7)
+		size, err := w.uint(offset, n)
+		if err != nil {
+			return offset, err
+		}
+		// one type byte follows the length
+		token.Family, header = fmt.Sprintf("ext%d", n*8), 2+n
+		if payload, err = w.fits(offset, header, size, token.Family); err != nil {
+			return offset, err
+		}
+	case c == 0xca:
+		token.Family, header = "float32", 5
+	case c == 0xcb:
+		token.Family, header = "float64", 9
+	case c >= 0xcc && c <= 0xcf:
+		n := 1 << (c - 0xcc)
+		v, err := w.uint(offset, n)
+		if err != nil {
+			return offset, err
+		}
+		token.Family, token.Value, header = fmt.Sprintf("uint%d", n*8), fmt.Sprint(v), 1+n
+	case c >= 0xd0 && c <= 0xd3:
+		n := 1 << (c - 0xd0)
+		v, err := w.uint(offset, n)
+		if err != nil {
+			return offset, err
+		}
+		var signed int64
+		switch n {
+		case 1:
+			signed = int64(int8(v))
+		case 2:
+			signed = int64(int16(v))
+		case 4:
+			signed = int64(int32(v))
+		default:
+			signed = int64(v)
+		}
+		token.Family, token.Value, header = fmt.Sprintf("int%d", n*8), fmt.Sprint(signed), 1+n
+	case c >= 0xd4 && c <= 0xd8:
+		token.Family, header, payload = fmt.Sprintf("fixext%d", 1<<(c-0xd4)), 2, 1<<(c-0xd4)
+	case c == 0xd9, c == 0xda, c == 0xdb:
+		n := 1 << (c - 0xd9)
+		size, err := w.uint(offset, n)
+		if err != nil {
+			return offset, err
+		}
+		token.Family, header = fmt.Sprintf("str%d", n*8), 1+n
+		if payload, err = w.fits(offset, header, size, token.Family); err != nil {
+			return offset, err
+		}
+	case c == 0xdc, c == 0xdd:
+		n := 2 << (c - 0xdc)
+		size, err := w.uint(offset, n)
+		if err != nil {
+			return offset, err
+		}
+		token.Family, header, isArray = fmt.Sprintf("array%d", n*8), 1+n, true
+		if count, err = w.fits(offset, header, size, token.Family); err != nil {
+			return offset, err
+		}
+	case c == 0xde, c == 0xdf:
+		n := 2 << (c - 0xde)
+		size, err := w.uint(offset, n)
+		if err != nil {
+			return offset, err
+		}
+		token.Family, header, isMap = fmt.Sprintf("map%d", n*8), 1+n, true
+		if count, err = w.fits(offset, header, size, token.Family); err != nil {
+			return offset, err
+		}
+	default:
+		return offset, w.errorAt(offset, "unknown format byte 0x%02x", c)
+	}
+
+	if offset+header+payload > len(w.data) {
+		return offset, w.errorAt(offset, "unexpected end of data in %s", token.Family)
+	}
+	token.Length = header + payload
+	w.fillValue(token, offset, header, payload)
+
+	if isKey && (isMap || isArray) {
+		return offset, w.errorAt(offset, "map keys must not be maps or arrays")
+	}
+	index := w.add(token)
+	if !isKey {
+		w.in.values[pathStr] = index
+	}
+	end := offset + token.Length
+
+	var err error
+	switch {
+	case isMap:
+		token.Value = fmt.Sprintf("%d entries", count)
+		for i := 0; i < count; i++ {
+			keyIndex := len(w.in.tokens)
+			end, err = w.value(end, path, depth+1, true)
+			if err != nil {
+				return end, err
+			}
+			key := w.in.tokens[keyIndex]
+			childPath := append(append([]string{}, path...), key.Value)
+			key.Path = strings.Join(childPath, "/")
+			end, err = w.value(end, childPath, depth+1, false)
+			if err != nil {
+				return end, err
+			}
+		}
+	case isArray:
+		token.Value = fmt.Sprintf("%d items", count)
+		for i := 0; i < count; i++ {
+			childPath := append(append([]string{}, path...), fmt.Sprint(i))
+			end, err = w.value(end, childPath, depth+1, false)
+			if err != nil {
+				return end, err
+			}
+		}
+	}
+	token.Size = end - offset
+	return end, nil
+}
+
+func (w *msgPackWalker) fillValue(token *HexToken, offset int, header int, payload int) {
+	body := w.data[offset+header : offset+header+payload]
+	switch {
+	case strings.HasPrefix(token.Family, "fixstr") || strings.HasPrefix(token.Family, "str"):
+		token.Value = string(body)
+	case strings.HasPrefix(token.Family, "bin"):
+		token.Value = fmt.Sprintf("%d bytes", payload)
+	case token.Family == "float32":
+		token.Value = fmt.Sprint(math.Float32frombits(binary.BigEndian.Uint32(w.data[offset+1 : offset+5])))
+	case token.Family == "float64":
+		token.Value = fmt.Sprint(math.Float64frombits(binary.BigEndian.Uint64(w.data[offset+1 : offset+9])))
+	case strings.HasPrefix(token.Family, "fixext") || strings.HasPrefix(token.Family, "ext"):
+		extType := int8(w.data[offset+header-1])
+		token.Value = fmt.Sprintf("ext type %d, %d bytes", extType, payload)
+		if extType == -1 {
+			if t, ok := msgPackTimestamp(body); ok {
+				token.Value = t.UTC().Format(time.RFC3339Nano)
+			}
+		}
+	}
+}
+
+// Decode the msgpack timestamp extension (type -1)
+func msgPackTimestamp(body []byte) (time.Time, bool) {
+	switch len(body) {
+	case 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(body)), 0), true
+	case 8:
+		v := binary.BigEndian.Uint64(body)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)), true
+	case 12:
+		nsec := binary.BigEndian.Uint32(body[:4])
+		sec := binary.BigEndian.Uint64(body[4:])
+		return time.Unix(int64(sec), int64(nsec)), true
+	}
+	return time.Time{}, false
+}
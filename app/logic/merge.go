@@ -0,0 +1,96 @@
+package logic
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Conflicts lists the paths a merge couldn't resolve on its own
+type Conflicts struct {
+	paths []string
+}
+
+func (c *Conflicts) Size() int {
+	return len(c.paths)
+}
+
+func (c *Conflicts) Get(i int) string {
+	if i < 0 || i >= len(c.paths) {
+		return ""
+	}
+	return c.paths[i]
+}
+
+/*
+Merge3 combines two edited copies of a document, local and remote, that both started from base.
+Maps are merged key by key; any other value (including arrays) is replaced as a whole.
+A value changed on only one side takes that side's change. When both sides changed the same
+value differently, local wins and the path is reported in Conflicts.
+The inputs are not modified.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func Merge3(base interface{}, local interface{}, remote interface{}) (interface{}, *Conflicts) {
+	conflicts := &Conflicts{}
+	merged := merge3(base, local, remote, []string{}, conflicts)
+	sort.Strings(conflicts.paths)
+	return merged, conflicts
+}
+
+func merge3(base interface{}, local interface{}, remote interface{}, path []string, conflicts *Conflicts) interface{} {
+	switch {
+	case reflect.DeepEqual(local, remote):
+		return cloneValue(local)
+	case reflect.DeepEqual(base, local):
+		return cloneValue(remote)
+	case reflect.DeepEqual(base, remote):
+		return cloneValue(local)
+	}
+
+	baseMap, _ := base.(map[string]interface{})
+	localMap, lok := local.(map[string]interface{})
+	remoteMap, rok := remote.(map[string]interface{})
+	if !lok || !rok {
+		conflicts.paths = append(conflicts.paths, strings.Join(path, "/"))
+		return cloneValue(local)
+	}
+
+	merged := make(map[string]interface{})
+	keys := make(map[string]bool)
+	for key := range localMap {
+		keys[key] = true
+	}
+	for key := range remoteMap {
+		keys[key] = true
+	}
+	for key := range keys {
+		baseValue, inBase := baseMap[key]
+		localValue, inLocal := localMap[key]
+		remoteValue, inRemote := remoteMap[key]
+		childPath := append(append([]string{}, path...), key)
+		switch {
+		case inLocal && inRemote:
+			merged[key] = merge3(baseValue, localValue, remoteValue, childPath, conflicts)
+		case inLocal:
+			// Removed remotely: keep the removal unless local changed the value
+			if inBase && reflect.DeepEqual(baseValue, localValue) {
+				continue
+			}
+			if inBase {
+				conflicts.paths = append(conflicts.paths, strings.Join(childPath, "/"))
+			}
+			merged[key] = cloneValue(localValue)
+		case inRemote:
+			// Removed locally: keep the removal unless remote changed the value
+			if inBase && reflect.DeepEqual(baseValue, remoteValue) {
+				continue
+			}
+			if inBase {
+				conflicts.paths = append(conflicts.paths, strings.Join(childPath, "/"))
+				continue
+			}
+			merged[key] = cloneValue(remoteValue)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,173 @@
+package logic
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of Schema
+const (
+	SchemaAny = iota
+	SchemaMap
+	SchemaArray
+	SchemaString
+	SchemaNumber
+	SchemaInt
+	SchemaBool
+)
+
+func SchemaKindString(kind int) string {
+	switch kind {
+	case SchemaAny:
+		return "any"
+	case SchemaMap:
+		return "map"
+	case SchemaArray:
+		return "array"
+	case SchemaString:
+		return "string"
+	case SchemaNumber:
+		return "number"
+	case SchemaInt:
+		return "int"
+	case SchemaBool:
+		return "bool"
+	}
+	return "unknown"
+}
+
+/*
+Schema describes the shape a document is expected to have.
+For SchemaMap, Properties gives the schema of known keys and Required the keys that must
+be present; other keys are allowed. For SchemaArray, Items is the schema of every element.
+SchemaInt accepts whole floats, since JSON numbers decode as float64. Nullable allows nil.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+type Schema struct {
+	Kind       int
+	Properties map[string]*Schema
+	Required   []string
+	Items      *Schema
+	Nullable   bool
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func MapSchema(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Kind: SchemaMap, Properties: properties, Required: required}
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func ArraySchema(items *Schema) *Schema {
+	return &Schema{Kind: SchemaArray, Items: items}
+}
+
+// A schema for a single value of kind, e.g. ValueSchema(SchemaString)
+// Only used w/in Go -- Ok to be skipped by gomobile
+func ValueSchema(kind int) *Schema {
+	return &Schema{Kind: kind}
+}
+
+// SchemaError says where a value didn't match its schema
+type SchemaError struct {
+	Path    string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Check value against the schema, returning a *SchemaError for the first mismatch in path order
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (s *Schema) Check(value interface{}) error {
+	if err := s.check(value, []string{}); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *Schema) check(value interface{}, path []string) *SchemaError {
+	mismatch := func(format string, args ...interface{}) *SchemaError {
+		return &SchemaError{Path: strings.Join(path, "/"), Message: fmt.Sprintf(format, args...)}
+	}
+	child := func(segment string) []string {
+		return append(append([]string{}, path...), segment)
+	}
+	if value == nil {
+		if s.Nullable || s.Kind == SchemaAny {
+			return nil
+		}
+		return mismatch("expected %s, found nothing", SchemaKindString(s.Kind))
+	}
+
+	switch s.Kind {
+	case SchemaAny:
+		return nil
+	case SchemaMap:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range s.Required {
+			if _, ok := m[key]; !ok {
+				return mismatch("missing required key %s", key)
+			}
+		}
+		keys := make([]string, 0, len(s.Properties))
+		for key := range s.Properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if v, ok := m[key]; ok {
+				if err := s.Properties[key].check(v, child(key)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case SchemaArray:
+		a, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+		if s.Items == nil {
+			return nil
+		}
+		for i, v := range a {
+			if err := s.Items.check(v, child(strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	case SchemaString:
+		if _, ok := value.(string); ok {
+			return nil
+		}
+	case SchemaBool:
+		if _, ok := value.(bool); ok {
+			return nil
+		}
+	case SchemaNumber, SchemaInt:
+		// Timestamps have a numeric value for stats but aren't numbers in a config document
+		if _, isTime := value.(time.Time); isTime {
+			break
+		}
+		f, ok := numericValue(value)
+		if !ok {
+			break
+		}
+		if s.Kind == SchemaInt && f != math.Trunc(f) {
+			return mismatch("expected int, found %v", value)
+		}
+		return nil
+	}
+	return mismatch("expected %s, found %s", SchemaKindString(s.Kind), TypeString(TypeOf(value)))
+}
@@ -0,0 +1,226 @@
+package logic
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Bytes a value takes up when encoded on its own in each format
+type EncodedSize struct {
+	Path    string
+	MsgPack int
+	Json    int
+	Yaml    int
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func EncodedSizeOf(path string, value interface{}) (*EncodedSize, error) {
+	size := &EncodedSize{Path: path}
+	data, err := EncodeMsgPack(value)
+	if err != nil {
+		return nil, err
+	}
+	size.MsgPack = len(data)
+	// Compact JSON, without the trailing newline the encoder adds
+	data, err = json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	size.Json = len(data)
+	// YAML depends on indentation, so this is the size of the subtree written as its own document
+	data, err = EncodeYaml(value)
+	if err != nil {
+		return nil, err
+	}
+	size.Yaml = len(data)
+	return size, nil
+}
+
+func (m *Map) EncodedSizeAt(path string) (*EncodedSize, error) {
+	value, err := getPathInterface(m.items, pathSlice(path))
+	if err != nil {
+		return nil, err
+	}
+	return EncodedSizeOf(path, value)
+}
+
+func (a *Array) EncodedSizeAt(path string) (*EncodedSize, error) {
+	value, err := getPathInterface(a.items, pathSlice(path))
+	if err != nil {
+		return nil, err
+	}
+	return EncodedSizeOf(path, value)
+}
+
+// One subtree in a SizeReport. KeyBytes is the msgpack size of the map key naming it (0 for array items).
+type SubtreeSize struct {
+	Path     string
+	Type     int
+	MsgPack  int
+	Json     int
+	KeyBytes int
+	Depth    int
+}
+
+// A map key name and what it costs across the whole document
+type KeyUsage struct {
+	Key   string
+	Count int
+	// msgpack bytes spent on this key name, summed over every occurrence
+	MsgPackBytes int
+	JsonBytes    int
+}
+
+/*
+SizeReport ranks the subtrees of a document by encoded msgpack size, largest first,
+and lists map key names by the total bytes spent repeating them.
+The root itself is not included in Subtrees.
+*/
+type SizeReport struct {
+	Total    *SubtreeSize
+	subtrees []*SubtreeSize
+	keys     []*KeyUsage
+}
+
+func (r *SizeReport) SubtreeCount() int {
+	return len(r.subtrees)
+}
+
+func (r *SizeReport) GetSubtree(i int) *SubtreeSize {
+	if i < 0 || i >= len(r.subtrees) {
+		return nil
+	}
+	return r.subtrees[i]
+}
+
+func (r *SizeReport) KeyCount() int {
+	return len(r.keys)
+}
+
+func (r *SizeReport) GetKey(i int) *KeyUsage {
+	if i < 0 || i >= len(r.keys) {
+		return nil
+	}
+	return r.keys[i]
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewSizeReport(root interface{}) (*SizeReport, error) {
+	s := &sizer{keys: make(map[string]*KeyUsage)}
+	total, err := s.walk(root, []string{}, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	report := &SizeReport{Total: total, subtrees: s.subtrees}
+	sort.SliceStable(report.subtrees, func(i, j int) bool {
+		return report.subtrees[i].MsgPack > report.subtrees[j].MsgPack
+	})
+	for _, usage := range s.keys {
+		report.keys = append(report.keys, usage)
+	}
+	sort.Slice(report.keys, func(i, j int) bool {
+		if report.keys[i].MsgPackBytes != report.keys[j].MsgPackBytes {
+			return report.keys[i].MsgPackBytes > report.keys[j].MsgPackBytes
+		}
+		return report.keys[i].Key < report.keys[j].Key
+	})
+	return report, nil
+}
+
+func (m *Map) SizeReport() (*SizeReport, error) {
+	return NewSizeReport(m.items)
+}
+
+func (a *Array) SizeReport() (*SizeReport, error) {
+	return NewSizeReport(a.items)
+}
+
+// sizer computes msgpack and compact JSON sizes bottom up so that each value is only encoded once
+type sizer struct {
+	subtrees []*SubtreeSize
+	keys     map[string]*KeyUsage
+}
+
+func (s *sizer) walk(value interface{}, path []string, keyBytes int, depth int) (*SubtreeSize, error) {
+	size := &SubtreeSize{Path: strings.Join(path, "/"), Type: int(TypeOf(value)), KeyBytes: keyBytes, Depth: depth}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		size.MsgPack = msgPackContainerHeader(len(v))
+		size.Json = 2
+		if len(v) > 1 {
+			size.Json += len(v) - 1
+		}
+		for key, child := range v {
+			keyMsgPack, err := msgpack.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			keyJson, err := json.Marshal(key)
+			if err != nil {
+				return nil, err
+			}
+			s.countKey(key, len(keyMsgPack), len(keyJson))
+			childSize, err := s.walk(child, append(path, key), len(keyMsgPack), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			size.MsgPack += len(keyMsgPack) + childSize.MsgPack
+			size.Json += len(keyJson) + 1 + childSize.Json
+		}
+	case []interface{}:
+		size.MsgPack = msgPackContainerHeader(len(v))
+		size.Json = 2
+		if len(v) > 1 {
+			size.Json += len(v) - 1
+		}
+		for i, child := range v {
+			childSize, err := s.walk(child, append(path, strconv.Itoa(i)), 0, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			size.MsgPack += childSize.MsgPack
+			size.Json += childSize.Json
+		}
+	default:
+		data, err := msgpack.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		size.MsgPack = len(data)
+		data, err = json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		size.Json = len(data)
+	}
+	if depth > 0 {
+		s.subtrees = append(s.subtrees, size)
+	}
+	return size, nil
+}
+
+func (s *sizer) countKey(key string, msgPackBytes int, jsonBytes int) {
+	usage, ok := s.keys[key]
+	if !ok {
+		usage = &KeyUsage{Key: key}
+		s.keys[key] = usage
+	}
+	usage.Count++
+	usage.MsgPackBytes += msgPackBytes
+	usage.JsonBytes += jsonBytes
+}
+
+// Size of a msgpack map or array header holding n entries
+func msgPackContainerHeader(n int) int {
+	switch {
+	case n < 16:
+		return 1
+	case n < 1<<16:
+		return 3
+	}
+	return 5
+}
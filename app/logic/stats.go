@@ -0,0 +1,262 @@
+package logic
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How many of the largest maps and arrays a Stats keeps
+const statsLargestCount = 10
+
+// A map or array and how many entries it holds
+type ContainerSize struct {
+	Path  string
+	Type  int
+	Count int
+}
+
+// Range of the numeric values found under a path pattern. Times are compared as unix milliseconds.
+type NumericRange struct {
+	// Path with array indices replaced by "*", e.g. devices/*/status
+	Pattern string
+	Count   int
+	Min     float64
+	Max     float64
+}
+
+/*
+StringLengthBucket counts strings with Min <= length <= Max.
+Buckets double in width: 0, 1, 2-3, 4-7, 8-15 ...
+*/
+type StringLengthBucket struct {
+	Min   int
+	Max   int
+	Count int
+}
+
+// Stats is a shape summary of a document
+type Stats struct {
+	MaxDepth         int
+	Nodes            int
+	Strings          int
+	MinStringLength  int
+	MaxStringLength  int
+	MeanStringLength float64
+	typeCounts       map[FieldType]int
+	largestMaps      []*ContainerSize
+	largestArrays    []*ContainerSize
+	stringBuckets    []*StringLengthBucket
+	numeric          []*NumericRange
+}
+
+// Number of nodes of a FieldType
+func (s *Stats) CountOf(fieldType int) int {
+	return s.typeCounts[FieldType(fieldType)]
+}
+
+func (s *Stats) LargestMapCount() int {
+	return len(s.largestMaps)
+}
+
+func (s *Stats) GetLargestMap(i int) *ContainerSize {
+	if i < 0 || i >= len(s.largestMaps) {
+		return nil
+	}
+	return s.largestMaps[i]
+}
+
+func (s *Stats) LargestArrayCount() int {
+	return len(s.largestArrays)
+}
+
+func (s *Stats) GetLargestArray(i int) *ContainerSize {
+	if i < 0 || i >= len(s.largestArrays) {
+		return nil
+	}
+	return s.largestArrays[i]
+}
+
+func (s *Stats) StringBucketCount() int {
+	return len(s.stringBuckets)
+}
+
+func (s *Stats) GetStringBucket(i int) *StringLengthBucket {
+	if i < 0 || i >= len(s.stringBuckets) {
+		return nil
+	}
+	return s.stringBuckets[i]
+}
+
+func (s *Stats) NumericRangeCount() int {
+	return len(s.numeric)
+}
+
+func (s *Stats) GetNumericRange(i int) *NumericRange {
+	if i < 0 || i >= len(s.numeric) {
+		return nil
+	}
+	return s.numeric[i]
+}
+
+func (s *Stats) DebugString() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "nodes: %d, max depth: %d\n", s.Nodes, s.MaxDepth)
+	for t := NilType; t <= UnknownType; t++ {
+		if count := s.typeCounts[t]; count > 0 {
+			fmt.Fprintf(&sb, "%s: %d\n", TypeString(t), count)
+		}
+	}
+	for _, m := range s.largestMaps {
+		fmt.Fprintf(&sb, "map %s: %d keys\n", m.Path, m.Count)
+	}
+	for _, a := range s.largestArrays {
+		fmt.Fprintf(&sb, "array %s: %d items\n", a.Path, a.Count)
+	}
+	for _, b := range s.stringBuckets {
+		fmt.Fprintf(&sb, "strings %d-%d: %d\n", b.Min, b.Max, b.Count)
+	}
+	for _, n := range s.numeric {
+		fmt.Fprintf(&sb, "%s: %v..%v (%d values)\n", n.Pattern, n.Min, n.Max, n.Count)
+	}
+	return sb.String()
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewStats(root interface{}) *Stats {
+	s := &Stats{typeCounts: make(map[FieldType]int), MinStringLength: -1}
+	numeric := make(map[string]*NumericRange)
+	totalStringLen := 0
+	var walk func(value interface{}, path []string, pattern []string, depth int)
+	walk = func(value interface{}, path []string, pattern []string, depth int) {
+		s.Nodes++
+		if depth > s.MaxDepth {
+			s.MaxDepth = depth
+		}
+		t := TypeOf(value)
+		s.typeCounts[t]++
+		switch v := value.(type) {
+		case map[string]interface{}:
+			s.largestMaps = append(s.largestMaps, &ContainerSize{Path: strings.Join(path, "/"), Type: int(t), Count: len(v)})
+			for key, child := range v {
+				walk(child, append(path, key), append(pattern, key), depth+1)
+			}
+		case []interface{}:
+			s.largestArrays = append(s.largestArrays, &ContainerSize{Path: strings.Join(path, "/"), Type: int(t), Count: len(v)})
+			for i, child := range v {
+				walk(child, append(path, strconv.Itoa(i)), append(pattern, "*"), depth+1)
+			}
+		case string:
+			s.Strings++
+			totalStringLen += len(v)
+			if s.MinStringLength < 0 || len(v) < s.MinStringLength {
+				s.MinStringLength = len(v)
+			}
+			if len(v) > s.MaxStringLength {
+				s.MaxStringLength = len(v)
+			}
+			s.countStringLength(len(v))
+		default:
+			if f, ok := numericValue(value); ok {
+				key := strings.Join(pattern, "/")
+				r, ok := numeric[key]
+				if !ok {
+					r = &NumericRange{Pattern: key, Min: f, Max: f}
+					numeric[key] = r
+				}
+				r.Count++
+				r.Min = math.Min(r.Min, f)
+				r.Max = math.Max(r.Max, f)
+			}
+		}
+	}
+	walk(root, []string{}, []string{}, 0)
+
+	if s.Strings > 0 {
+		s.MeanStringLength = float64(totalStringLen) / float64(s.Strings)
+	} else {
+		s.MinStringLength = 0
+	}
+	s.largestMaps = largestContainers(s.largestMaps)
+	s.largestArrays = largestContainers(s.largestArrays)
+	for _, r := range numeric {
+		s.numeric = append(s.numeric, r)
+	}
+	sort.Slice(s.numeric, func(i, j int) bool {
+		return s.numeric[i].Pattern < s.numeric[j].Pattern
+	})
+	return s
+}
+
+func (m *Map) Stats() *Stats {
+	return NewStats(m.items)
+}
+
+func (a *Array) Stats() *Stats {
+	return NewStats(a.items)
+}
+
+func (s *Stats) countStringLength(length int) {
+	bucket := 0
+	for width := length; width > 0; width >>= 1 {
+		bucket++
+	}
+	for len(s.stringBuckets) <= bucket {
+		i := len(s.stringBuckets)
+		b := &StringLengthBucket{}
+		if i > 0 {
+			b.Min = 1 << (i - 1)
+			b.Max = 1<<i - 1
+		}
+		s.stringBuckets = append(s.stringBuckets, b)
+	}
+	s.stringBuckets[bucket].Count++
+}
+
+func largestContainers(containers []*ContainerSize) []*ContainerSize {
+	sort.SliceStable(containers, func(i, j int) bool {
+		if containers[i].Count != containers[j].Count {
+			return containers[i].Count > containers[j].Count
+		}
+		return containers[i].Path < containers[j].Path
+	})
+	if len(containers) > statsLargestCount {
+		containers = containers[:statsLargestCount]
+	}
+	return containers
+}
+
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case time.Time:
+		return float64(v.UnixMilli()), true
+	}
+	return 0, false
+}
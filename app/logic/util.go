@@ -22,25 +22,30 @@ func getPathInterface(root interface{}, path []string) (interface{}, error) {
 		if a, ok := current.([]interface{}); ok {
 			index, err := strconv.Atoi(k)
 			if err != nil {
-				return nil, err
+				return nil, invalidIndexError(path, idx, err)
 			}
-			if index >= len(a) {
-				return nil, fmt.Errorf("index out of bounds %d; len: %d", index, len(a))
+			if index < 0 || index >= len(a) {
+				return nil, outOfBoundsError(path, idx, index, len(a))
 			}
 			value = a[index]
 		}
-		if m, ok := current.(map[string]interface{}); ok {
+		m, mok := current.(map[string]interface{})
+		if mok {
+			var ok bool
 			value, ok = m[k]
 			if !ok {
-				return nil, fmt.Errorf("unknown field %s", k)
+				return nil, notFoundError(path, idx)
 			}
 		}
+		if _, aok := current.([]interface{}); !aok && !mok {
+			return nil, notContainerError(path, idx-1, current)
+		}
 		// If we have more path to process, our current value should be an array or map
 		if idx < len(path)-1 {
 			_, aok := value.([]interface{})
 			_, mok := value.(map[string]interface{})
 			if !aok && !mok {
-				return nil, fmt.Errorf("%s is not an array or dictionary", k)
+				return nil, notContainerError(path, idx, value)
 			}
 		}
 		current = value
@@ -61,19 +66,28 @@ func getPath(root interface{}, path []string) (*Field, error) {
 }
 
 func setPath(root interface{}, path []string, value interface{}) (interface{}, error) {
-	if len(path) == 0 {
+	return setPathFrom(root, path, 0, value)
+}
+
+// setPathFrom sets path[depth:] below root, keeping the full path for error reporting
+func setPathFrom(root interface{}, path []string, depth int, value interface{}) (interface{}, error) {
+	if depth >= len(path) {
 		return root, nil
 	}
 
-	if len(path) == 1 {
+	key := path[depth]
+	if depth == len(path)-1 {
 		if m, ok := root.(map[string]interface{}); ok {
-			m[path[0]] = value
+			m[key] = value
 			return m, nil
 		}
 		if a, ok := root.([]interface{}); ok {
-			index, err := strconv.Atoi(path[0])
+			index, err := strconv.Atoi(key)
 			if err != nil {
-				return nil, err
+				return nil, invalidIndexError(path, depth, err)
+			}
+			if index < 0 {
+				return nil, outOfBoundsError(path, depth, index, len(a))
 			}
 			if index < len(a) {
 				a[index] = value
@@ -82,12 +96,11 @@ func setPath(root interface{}, path []string, value interface{}) (interface{}, e
 			a = append(a, value)
 			return a, nil
 		}
-		return nil, fmt.Errorf("parent field to %s is not a map or an array", path[0])
+		return nil, notContainerError(path, depth-1, root)
 	}
 
-	key, rest := path[0], path[1:]
 	if m, ok := root.(map[string]interface{}); ok {
-		result, err := setPath(m[key], rest, value)
+		result, err := setPathFrom(m[key], path, depth+1, value)
 		if err != nil {
 			return nil, err
 		}
@@ -97,19 +110,19 @@ func setPath(root interface{}, path []string, value interface{}) (interface{}, e
 	if a, ok := root.([]interface{}); ok {
 		index, err := strconv.Atoi(key)
 		if err != nil {
-			return nil, err
+			return nil, invalidIndexError(path, depth, err)
 		}
-		if index >= len(a) {
-			return nil, fmt.Errorf("index out of bounds %d", index)
+		if index < 0 || index >= len(a) {
+			return nil, outOfBoundsError(path, depth, index, len(a))
 		}
-		result, err := setPath(a[index], rest, value)
+		result, err := setPathFrom(a[index], path, depth+1, value)
 		if err != nil {
 			return nil, err
 		}
 		a[index] = result
 		return a, nil
 	}
-	return nil, fmt.Errorf("parent field to %s is not a map or an array", key)
+	return nil, notContainerError(path, depth-1, root)
 
 }
 
@@ -128,7 +141,7 @@ func keySizeAt(root interface{}, path []string) (int, error) {
 		return len(pm), nil
 	}
 	fmt.Println("Unknown type in keySizeAt")
-	return 0, fmt.Errorf("field %s is not a map or an array", path[len(path)-1])
+	return 0, notContainerError(path, len(path)-1, value)
 }
 
 func getKeyAt(root interface{}, path []string, index int) (string, error) {
@@ -139,14 +152,14 @@ func getKeyAt(root interface{}, path []string, index int) (string, error) {
 	pa, aok := parent.([]interface{})
 	pm, mok := parent.(map[string]interface{})
 	if aok {
-		if index >= len(pa) {
-			return "", fmt.Errorf("index %d out of bounds %d", index, len(pa))
+		if index < 0 || index >= len(pa) {
+			return "", outOfBoundsError(append(path, fmt.Sprintf("%d", index)), len(path), index, len(pa))
 		}
 		return fmt.Sprintf("%d", index), nil
 	}
 	if mok {
-		if index > len(pm) {
-			return "", fmt.Errorf("index %d out of bounds %d", index, len(pm))
+		if index < 0 || index >= len(pm) {
+			return "", outOfBoundsError(append(path, fmt.Sprintf("%d", index)), len(path), index, len(pm))
 		}
 		keys := make(sort.StringSlice, 0, len(pm))
 		for key := range pm {
@@ -155,7 +168,7 @@ func getKeyAt(root interface{}, path []string, index int) (string, error) {
 		sort.Sort(keys)
 		return keys[index], nil
 	}
-	return "", fmt.Errorf("field %s is not a map or an array", path[len(path)-1])
+	return "", notContainerError(path, len(path)-1, parent)
 }
 
 func debugString(root interface{}) string {
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"github.com/marcuswu/msgpack/mobile/router"
+)
+
+// Screens the app can navigate to
+const (
+	SplashRoute = "splash"
+	HomeRoute   = "home"
+	ViewerRoute = "viewer"
+)
+
+// Route arguments
+const (
+	// The path or platform uri of the file to open
+	FileArg = "file"
+	// Open the file from its interrupted session rather than from disk
+	RestoreArg = "restore"
+	// Path of the node to show first, e.g. devices/3/status
+	PathArg = "path"
+)
+
+var routes = newRoutes()
+
+func newRoutes() *router.RouteTable {
+	table := router.NewRouteTable()
+	table.Register(router.NewRoute(SplashRoute))
+	table.Register(router.NewRoute(HomeRoute))
+	table.Register(router.NewRoute(ViewerRoute,
+		&router.Param{Name: FileArg, Type: router.StringParam, Required: true},
+		&router.Param{Name: RestoreArg, Type: router.BoolParam},
+		&router.Param{Name: PathArg, Type: router.StringParam},
+	))
+	return table
+}
+
+// Scheme of links that open the app
+const LinkScheme = "msgpack"
+
+var links = newLinks()
+
+func newLinks() *router.DeepLinks {
+	links := router.NewDeepLinks(LinkScheme, routes)
+	// msgpack://open?file=...&path=/devices/3/status
+	// restore isn't allowed: only the app knows whether there is a session to restore
+	links.Register("open", ViewerRoute, FileArg, PathArg)
+	return links
+}
+
+func DeepLinks() *router.DeepLinks {
+	return links
+}
+
+// Navigate to where link leads, e.g. when another app or a notification opens it
+func OpenLink(link string) error {
+	return defaultContainer.OpenLink(link)
+}
+
+// The route table, for the platform to parse the locations passed to Router.Navigate
+func Routes() *router.RouteTable {
+	return routes
+}
+
+// Validate args against route name and navigate there with them
+// Only used w/in Go -- Ok to be skipped by gomobile
+func Navigate(name string, args *router.Args) error {
+	return defaultContainer.Navigate(name, args)
+}
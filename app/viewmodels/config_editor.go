@@ -0,0 +1,274 @@
+package viewmodels
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/marcuswu/msgpack/app"
+	"github.com/marcuswu/msgpack/app/firebase"
+	"github.com/marcuswu/msgpack/app/logic"
+	"github.com/marcuswu/msgpack/mobile/service"
+	"github.com/marcuswu/msgpack/mobile/viewmodel"
+)
+
+/*
+ViewModel for the remote config debug screen
+config editor actions:
+* Browse the effective config, with JSON values shown as trees
+* Edit values in the document editor (Editor) and apply them as local overrides
+* Remove one override or all of them
+*/
+type ConfigEditorState struct {
+	// Every declared flag with its value and where it came from
+	Flags *firebase.FlagValues `msgpack:"-"`
+	// Keys edited in the editor but not yet applied
+	Pending []string `msgpack:"-"`
+	Error   error    `msgpack:"-"`
+}
+
+func (s *ConfigEditorState) Clone() *ConfigEditorState {
+	return &ConfigEditorState{Flags: s.Flags, Pending: append([]string{}, s.Pending...), Error: s.Error}
+}
+
+func (s *ConfigEditorState) PendingCount() int {
+	return len(s.Pending)
+}
+
+func (s *ConfigEditorState) GetPending(i int) string {
+	if i < 0 || i >= len(s.Pending) {
+		return ""
+	}
+	return s.Pending[i]
+}
+
+type ConfigEditorStateFunc interface {
+	WithState(*ConfigEditorState)
+}
+type ConfigEditorScreenFunc struct {
+	StateFunc func(*ConfigEditorState)
+}
+
+func (sf *ConfigEditorScreenFunc) WithState(state *ConfigEditorState) {
+	sf.StateFunc(state)
+}
+
+type ConfigEditorStateObserver interface {
+	Update(*ConfigEditorState)
+}
+
+type ConfigEditorViewModel struct {
+	viewmodel.Lifecycle
+	state     atomic.Value
+	observers map[string]ConfigEditorStateObserver
+	editor    *ViewerViewModel
+	// The document the editor was last loaded with
+	effective map[string]interface{}
+	app       *app.Container
+}
+
+func NewConfigEditorViewModel() *ConfigEditorViewModel {
+	return NewConfigEditorViewModelWith(app.Default())
+}
+
+// Edits the config of container
+func NewConfigEditorViewModelWith(container *app.Container) *ConfigEditorViewModel {
+	container = containerOrDefault(container)
+	vm := &ConfigEditorViewModel{observers: make(map[string]ConfigEditorStateObserver), editor: newViewerViewModel(container), app: container}
+	vm.UpdateState(&ConfigEditorState{})
+	vm.editor.Observe("config_editor", &configEditorObserver{vm: vm})
+	vm.OnClear(vm.editor.Clear)
+	vm.Reload()
+	return vm
+}
+
+func (b *ConfigEditorViewModel) UpdateState(newState *ConfigEditorState) {
+	b.state.Store(newState)
+	b.Notify(func() {
+		for _, sub := range b.observers {
+			sub.Update(b.state.Load().(*ConfigEditorState))
+		}
+	})
+}
+
+func (b *ConfigEditorViewModel) CloneState() *ConfigEditorState {
+	return b.state.Load().(*ConfigEditorState).Clone()
+}
+
+func (b *ConfigEditorViewModel) WithState(stateFunc ConfigEditorStateFunc) {
+	stateFunc.WithState(b.state.Load().(*ConfigEditorState))
+}
+
+func (b *ConfigEditorViewModel) Observe(id string, callback ConfigEditorStateObserver) {
+	b.observers[id] = callback
+}
+
+// The effective config as a document, one top-level key per flag, for the document editor screens
+func (vm *ConfigEditorViewModel) Editor() *ViewerViewModel {
+	return vm.editor
+}
+
+// Rebuild the document from the effective config, dropping edits that weren't applied
+func (vm *ConfigEditorViewModel) Reload() {
+	flags := vm.app.FlagValues()
+	document := make(map[string]interface{})
+	for i := 0; i < flags.Size(); i++ {
+		flag := flags.Get(i)
+		value, err := parseFlagValue(flag.Type, flag.Value)
+		if err != nil {
+			// Show what can't be parsed as the raw string so it can still be fixed
+			value = flag.Value
+		}
+		document[flag.Key] = value
+	}
+	vm.effective = document
+
+	data := logic.NewFieldWithValue("", cloneDocument(document))
+	vm.editor.UpdateState(&MsgPackViewerState{
+		Data:   data,
+		Load:   service.Resource[*logic.Field]{State: service.Success, Result: data},
+		format: jsonFormat,
+	})
+	state := vm.CloneState()
+	state.Flags = flags
+	state.Pending = nil
+	state.Error = nil
+	vm.UpdateState(state)
+}
+
+// Store the edited values of every changed flag as overrides
+func (vm *ConfigEditorViewModel) Apply() {
+	overrides := vm.app.ConfigOverrides()
+	if overrides == nil {
+		vm.fail(errors.New("no remote config has been set"))
+		return
+	}
+	edited := vm.edited()
+	current := vm.state.Load().(*ConfigEditorState)
+	for _, key := range current.Pending {
+		var flagType int
+		if flag := current.Flags.Find(key); flag != nil {
+			flagType = flag.Type
+		}
+		value, err := formatOverride(flagType, edited[key])
+		if err == nil {
+			err = overrides.Set(key, value)
+		}
+		if err != nil {
+			vm.fail(fmt.Errorf("could not override %s: %w", key, err))
+			return
+		}
+	}
+	vm.Reload()
+}
+
+// Go back to the remote or default value of key
+func (vm *ConfigEditorViewModel) RemoveOverride(key string) {
+	if overrides := vm.app.ConfigOverrides(); overrides != nil {
+		if err := overrides.Remove(key); err != nil {
+			vm.fail(err)
+			return
+		}
+	}
+	vm.Reload()
+}
+
+func (vm *ConfigEditorViewModel) ClearOverrides() {
+	if overrides := vm.app.ConfigOverrides(); overrides != nil {
+		if err := overrides.Clear(); err != nil {
+			vm.fail(err)
+			return
+		}
+	}
+	vm.Reload()
+}
+
+func (vm *ConfigEditorViewModel) fail(err error) {
+	state := vm.CloneState()
+	state.Error = err
+	vm.UpdateState(state)
+}
+
+func (vm *ConfigEditorViewModel) edited() map[string]interface{} {
+	data := vm.editor.state.Load().(*MsgPackViewerState).Data
+	if data == nil {
+		return nil
+	}
+	document, _ := data.Value().(map[string]interface{})
+	return document
+}
+
+// Keeps Pending in step with edits made in the editor
+type configEditorObserver struct {
+	vm *ConfigEditorViewModel
+}
+
+func (o *configEditorObserver) Update(editorState *MsgPackViewerState) {
+	edited := o.vm.edited()
+	pending := make([]string, 0)
+	for key, value := range o.vm.effective {
+		if !reflect.DeepEqual(edited[key], value) {
+			pending = append(pending, key)
+		}
+	}
+	sort.Strings(pending)
+	current := o.vm.state.Load().(*ConfigEditorState)
+	if len(pending) == 0 && len(current.Pending) == 0 {
+		return
+	}
+	state := current.Clone()
+	state.Pending = pending
+	o.vm.UpdateState(state)
+}
+
+func cloneDocument(document map[string]interface{}) map[string]interface{} {
+	return logic.NewFieldWithValue("", document).Clone().Value().(map[string]interface{})
+}
+
+// The value of a flag as it is shown in the editor; JSON flags become trees
+func parseFlagValue(flagType int, value string) (interface{}, error) {
+	switch flagType {
+	case firebase.BoolType:
+		return strconv.ParseBool(value)
+	case firebase.IntType:
+		return strconv.Atoi(value)
+	case firebase.FloatType:
+		return strconv.ParseFloat(value, 64)
+	case firebase.JsonType:
+		var tree interface{}
+		err := json.Unmarshal([]byte(value), &tree)
+		return tree, err
+	}
+	return value, nil
+}
+
+// The string remote config would deliver for value
+func formatOverride(flagType int, value interface{}) (string, error) {
+	if flagType == firebase.JsonType {
+		data, err := json.Marshal(value)
+		return string(data), err
+	}
+	if s, ok := value.(string); ok {
+		// Check strings typed into a non-string flag parse as its type
+		_, err := parseFlagValue(flagType, s)
+		return s, err
+	}
+	switch v := value.(type) {
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		if flagType == firebase.IntType {
+			return strconv.Itoa(int(v)), nil
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), nil
+	case nil:
+		return "", errors.New("no value")
+	}
+	return fmt.Sprint(value), nil
+}
@@ -0,0 +1,157 @@
+package viewmodels
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+
+	"github.com/marcuswu/msgpack/app/files"
+	"github.com/marcuswu/msgpack/app/logic"
+)
+
+// Reported through SaveError when Save finds the file was changed by someone else
+var ErrExternalChange = errors.New("the file was changed outside of the app")
+
+/*
+diskVersion is what the file looked like when it was loaded or last saved.
+base is the decoded document at that point, used as the common ancestor when merging.
+*/
+type diskVersion struct {
+	modified int64
+	hash     [sha256.Size]byte
+	base     interface{}
+}
+
+// The on-disk version of filename, nil when there is none. data may be nil when the file couldn't be decoded, leaving no merge base.
+func (vm *ViewerViewModel) diskVersionOf(filename string, fileData []byte, data *logic.Field) *diskVersion {
+	if filename == "" {
+		return nil
+	}
+	info, err := vm.app.FileProvider().Stat(filename)
+	if err != nil || !info.Exists {
+		return nil
+	}
+	disk := &diskVersion{modified: info.Modified, hash: sha256.Sum256(fileData)}
+	if data != nil {
+		disk.base = data.Clone().Value()
+	}
+	return disk
+}
+
+// A version no file on disk matches, so the next save reports ErrExternalChange rather than overwriting a file that couldn't be checked
+func unknownDisk() *diskVersion {
+	return &diskVersion{modified: -1}
+}
+
+/*
+externalChange checks whether filename differs from disk, the version it was loaded or saved as.
+The modification time is checked first so an unchanged file isn't read back. When the file
+is touched without its contents changing the new time is remembered. A deleted file counts
+as changed with no data.
+*/
+func (vm *ViewerViewModel) externalChange(filename string, disk *diskVersion) ([]byte, bool, error) {
+	if disk == nil {
+		return nil, false, nil
+	}
+	provider := vm.app.FileProvider()
+	info, err := provider.Stat(filename)
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.Exists {
+		return nil, true, nil
+	}
+	if info.Modified == disk.modified {
+		return nil, false, nil
+	}
+	fileData, err := files.ReadAll(provider, filename)
+	if err != nil {
+		return nil, false, err
+	}
+	hash := sha256.Sum256(fileData)
+	if bytes.Equal(hash[:], disk.hash[:]) {
+		disk.modified = info.Modified
+		return nil, false, nil
+	}
+	return fileData, true, nil
+}
+
+// Poll for changes made to the file outside of the app, e.g. when the screen is resumed
+func (vm *ViewerViewModel) CheckExternalChanges() {
+	current := vm.state.Load().(*MsgPackViewerState)
+	_, changed, err := vm.externalChange(current.Filename, current.disk())
+	if err != nil {
+		vm.app.Logger().Printf("Failed to check for external changes: %s\n", err.Error())
+		return
+	}
+	if changed == current.ExternalChange {
+		return
+	}
+	state := vm.CloneState()
+	state.ExternalChange = changed
+	vm.UpdateState(state)
+}
+
+// Resolve an external change by dropping local edits and loading the file as it is now
+func (vm *ViewerViewModel) ReloadFromDisk() {
+	current := vm.state.Load().(*MsgPackViewerState)
+	vm.discardJournal(current)
+	vm.LoadFile(current.Filename)
+}
+
+// Resolve an external change by writing the local document over the file
+func (vm *ViewerViewModel) OverwriteDisk() {
+	vm.saveAs(vm.state.Load().(*MsgPackViewerState).Filename, true, "")
+}
+
+/*
+MergeExternal resolves an external change by merging the file's new contents into the
+local document. Paths edited on both sides keep the local value and are listed in Conflicts.
+The merged document is unsaved; saving it no longer conflicts with the file.
+*/
+func (vm *ViewerViewModel) MergeExternal() {
+	state := vm.CloneState()
+	err := documentError(state)
+	var fileData []byte
+	var changed bool
+	if err == nil {
+		fileData, changed, err = vm.externalChange(state.Filename, state.disk())
+	}
+	if err == nil && changed && fileData == nil {
+		err = errors.New("the file was deleted; save to recreate it")
+	}
+	if err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return
+	}
+	if !changed {
+		state.ExternalChange = false
+		vm.UpdateState(state)
+		return
+	}
+
+	remote, _, decodeErr := vm.decode(fileData)
+	if decodeErr != nil {
+		state.Error = decodeErr
+		vm.UpdateState(state)
+		return
+	}
+	merged, conflicts := logic.Merge3(state.disk().base, state.Data.Value(), remote.Value())
+	vm.app.Logger().Printf("Merged external changes with %d conflicts", conflicts.Size())
+	state.Data = logic.NewFieldWithValue("", merged)
+	state.Conflicts = conflicts
+	state.ExternalChange = false
+	state.Dirty = true
+	state.Load.Result = state.Data
+	state.source = &documentSource{fileData: fileData, journal: state.journal(), disk: vm.diskVersionOf(state.Filename, fileData, remote)}
+	vm.snapshotDraft(state)
+	vm.UpdateState(state)
+}
+
+// Acknowledge the merge conflicts once the user has reviewed them
+func (vm *ViewerViewModel) ClearConflicts() {
+	state := vm.CloneState()
+	state.Conflicts = nil
+	vm.UpdateState(state)
+}
@@ -0,0 +1,11 @@
+package viewmodels
+
+import "github.com/marcuswu/msgpack/app"
+
+// The container a view model built with container uses: the Default one when container is nil
+func containerOrDefault(container *app.Container) *app.Container {
+	if container == nil {
+		return app.Default()
+	}
+	return container
+}
@@ -0,0 +1,435 @@
+package viewmodels
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/marcuswu/msgpack/app"
+	"github.com/marcuswu/msgpack/app/files"
+	"github.com/marcuswu/msgpack/mobile/viewmodel"
+)
+
+/*
+ViewModel for the set of open documents (tabs)
+documents actions:
+* Open a file in a new tab, or switch to it if it is already open
+* Switch tabs
+* Close a tab, prompting when it has unsaved changes
+*/
+
+// One open document. LastActive is unix milliseconds; Loaded is false once the document has been evicted.
+type Document struct {
+	Id         string
+	Filename   string
+	Name       string
+	Dirty      bool
+	Loaded     bool
+	LastActive int64
+}
+
+type DocumentsState struct {
+	documents []*Document
+	// Id of the document being shown, "" when none are open
+	Active string
+	// Id of a dirty document the user asked to close; the UI should ask whether to save it
+	PendingClose string
+	Error        error
+}
+
+func (s *DocumentsState) Clone() *DocumentsState {
+	documents := make([]*Document, 0, len(s.documents))
+	for _, doc := range s.documents {
+		c := *doc
+		documents = append(documents, &c)
+	}
+	return &DocumentsState{documents: documents, Active: s.Active, PendingClose: s.PendingClose, Error: s.Error}
+}
+
+func (s *DocumentsState) Size() int {
+	return len(s.documents)
+}
+
+func (s *DocumentsState) Get(i int) *Document {
+	if i < 0 || i >= len(s.documents) {
+		return nil
+	}
+	return s.documents[i]
+}
+
+func (s *DocumentsState) Find(id string) *Document {
+	for _, doc := range s.documents {
+		if doc.Id == id {
+			return doc
+		}
+	}
+	return nil
+}
+
+func (s *DocumentsState) remove(id string) {
+	for i, doc := range s.documents {
+		if doc.Id == id {
+			s.documents = append(s.documents[:i], s.documents[i+1:]...)
+			return
+		}
+	}
+}
+
+type DocumentsStateFunc interface {
+	WithState(*DocumentsState)
+}
+type DocumentsScreenFunc struct {
+	StateFunc func(*DocumentsState)
+}
+
+func (sf *DocumentsScreenFunc) WithState(state *DocumentsState) {
+	sf.StateFunc(state)
+}
+
+type DocumentsStateObserver interface {
+	Update(*DocumentsState)
+}
+
+type DocumentsViewModel struct {
+	viewmodel.Lifecycle
+	// Held while the state is read, changed and stored; viewers report changes from their load goroutines
+	mu          sync.Mutex
+	state       atomic.Value
+	observersMu sync.RWMutex
+	observers   map[string]DocumentsStateObserver
+	viewers     map[string]*ViewerViewModel
+	nextId      int
+	maxLoaded   int
+	app         *app.Container
+}
+
+func NewDocumentsViewModel() *DocumentsViewModel {
+	return NewDocumentsViewModelWith(app.Default())
+}
+
+// Documents are opened with container too
+func NewDocumentsViewModelWith(container *app.Container) *DocumentsViewModel {
+	container = containerOrDefault(container)
+	vm := &DocumentsViewModel{
+		observers: make(map[string]DocumentsStateObserver),
+		viewers:   make(map[string]*ViewerViewModel),
+		maxLoaded: app.MaxLoadedDocuments.GetFrom(container.Config()),
+		app:       container,
+	}
+	vm.OnClear(func() {
+		for _, viewer := range vm.viewers {
+			viewer.Clear()
+		}
+	})
+	vm.UpdateState(&DocumentsState{})
+	return vm
+}
+
+func (b *DocumentsViewModel) UpdateState(newState *DocumentsState) {
+	b.mu.Lock()
+	b.state.Store(newState)
+	b.mu.Unlock()
+	b.notify()
+}
+
+// Apply change to a copy of the state and publish it, one change at a time
+func (b *DocumentsViewModel) update(change func(*DocumentsState)) {
+	b.mu.Lock()
+	newState := b.CloneState()
+	change(newState)
+	b.state.Store(newState)
+	b.mu.Unlock()
+	b.notify()
+}
+
+func (b *DocumentsViewModel) notify() {
+	b.Notify(func() {
+		b.observersMu.RLock()
+		observers := make([]DocumentsStateObserver, 0, len(b.observers))
+		for _, sub := range b.observers {
+			observers = append(observers, sub)
+		}
+		b.observersMu.RUnlock()
+		for _, sub := range observers {
+			sub.Update(b.state.Load().(*DocumentsState))
+		}
+	})
+}
+
+func (b *DocumentsViewModel) CloneState() *DocumentsState {
+	return b.state.Load().(*DocumentsState).Clone()
+}
+
+func (b *DocumentsViewModel) WithState(stateFunc DocumentsStateFunc) {
+	stateFunc.WithState(b.state.Load().(*DocumentsState))
+}
+
+func (b *DocumentsViewModel) Observe(id string, callback DocumentsStateObserver) {
+	b.observersMu.Lock()
+	defer b.observersMu.Unlock()
+	b.observers[id] = callback
+}
+
+// Maximum number of documents kept decoded; clean documents beyond it are evicted and reloaded when shown
+func (vm *DocumentsViewModel) SetMaxLoaded(max int) {
+	if max < 1 {
+		max = 1
+	}
+	vm.update(func(state *DocumentsState) {
+		vm.maxLoaded = max
+		vm.evict(state)
+	})
+}
+
+// Open filename in a new tab, or switch to its tab if it is already open. Returns the document id.
+func (vm *DocumentsViewModel) Open(filename string) string {
+	var id string
+	vm.update(func(state *DocumentsState) {
+		doc := vm.open(state, filename, nil)
+		id = doc.Id
+	})
+	return id
+}
+
+/*
+Add a document that was restored from the edit journal (see RestoreViewerViewModel).
+A file that is already open is switched to instead, as its tab holds the same edits.
+*/
+func (vm *DocumentsViewModel) OpenRestored(filename string) string {
+	var id string
+	vm.update(func(state *DocumentsState) {
+		doc := vm.open(state, filename, func() *ViewerViewModel {
+			return RestoreViewerViewModelWith(vm.app, filename)
+		})
+		id = doc.Id
+	})
+	return id
+}
+
+// Switch to filename's tab, opening it with newViewer (or from the file when nil) if it isn't open yet
+func (vm *DocumentsViewModel) open(state *DocumentsState, filename string, newViewer func() *ViewerViewModel) *Document {
+	for _, doc := range state.documents {
+		if doc.Filename == filename {
+			vm.activate(state, doc)
+			return doc
+		}
+	}
+
+	vm.nextId++
+	doc := &Document{Id: fmt.Sprintf("doc-%d", vm.nextId), Filename: filename, Name: filepath.Base(filename)}
+	if newViewer != nil {
+		vm.attach(doc, newViewer())
+	}
+	state.documents = append(state.documents, doc)
+	vm.activate(state, doc)
+	return doc
+}
+
+func (vm *DocumentsViewModel) Activate(id string) {
+	vm.update(func(state *DocumentsState) {
+		doc := state.Find(id)
+		if doc == nil {
+			state.Error = fmt.Errorf("no open document %s", id)
+			return
+		}
+		vm.activate(state, doc)
+	})
+}
+
+// The view model for a document, loading it again if it was evicted. Returns nil for an unknown id.
+func (vm *DocumentsViewModel) Viewer(id string) *ViewerViewModel {
+	if viewer, ok := vm.viewers[id]; ok {
+		return viewer
+	}
+	if vm.state.Load().(*DocumentsState).Find(id) == nil {
+		return nil
+	}
+	vm.update(func(state *DocumentsState) {
+		if doc := state.Find(id); doc != nil {
+			vm.load(doc)
+			vm.evict(state)
+		}
+	})
+	return vm.viewers[id]
+}
+
+// The view model for the active document, or nil when nothing is open
+func (vm *DocumentsViewModel) ActiveViewer() *ViewerViewModel {
+	active := vm.state.Load().(*DocumentsState).Active
+	if active == "" {
+		return nil
+	}
+	return vm.Viewer(active)
+}
+
+// Close a document. A document with unsaved changes is not closed; PendingClose is set so the UI can prompt.
+func (vm *DocumentsViewModel) Close(id string) {
+	vm.update(func(state *DocumentsState) {
+		doc := state.Find(id)
+		if doc == nil {
+			return
+		}
+		if doc.Dirty {
+			state.PendingClose = id
+			return
+		}
+		vm.close(state, doc)
+	})
+}
+
+// Answer the unsaved changes prompt by saving, then closing if the save worked
+func (vm *DocumentsViewModel) SaveAndClose() {
+	pending := vm.state.Load().(*DocumentsState).PendingClose
+	if pending == "" {
+		return
+	}
+	err := fmt.Errorf("no open document %s", pending)
+	// The viewer saves and notifies its observers outside of update, as documentObserver updates this state
+	if viewer := vm.Viewer(pending); viewer != nil {
+		viewer.Save()
+		err = viewer.CloneState().SaveError
+	}
+	vm.update(func(state *DocumentsState) {
+		if err != nil {
+			state.PendingClose = ""
+			state.Error = err
+			return
+		}
+		if doc := state.Find(pending); doc != nil {
+			vm.close(state, doc)
+		}
+	})
+}
+
+// Answer the unsaved changes prompt by throwing the changes away. The document is closed as it is, without reloading the file.
+func (vm *DocumentsViewModel) DiscardAndClose() {
+	vm.update(func(state *DocumentsState) {
+		doc := state.Find(state.PendingClose)
+		if doc == nil {
+			return
+		}
+		if viewer, ok := vm.viewers[doc.Id]; ok {
+			viewer.discardJournal(viewer.state.Load().(*MsgPackViewerState))
+		} else if vm.app.StorageDir() != "" {
+			if err := files.DiscardSession(vm.app.StorageDir(), doc.Filename); err != nil {
+				vm.app.Logger().Printf("Failed to discard edit journal: %s\n", err.Error())
+			}
+		}
+		vm.close(state, doc)
+	})
+}
+
+func (vm *DocumentsViewModel) CancelClose() {
+	vm.update(func(state *DocumentsState) {
+		state.PendingClose = ""
+	})
+}
+
+// Whether any open document has unsaved changes, e.g. before leaving the app
+func (vm *DocumentsViewModel) HasUnsavedChanges() bool {
+	for _, doc := range vm.state.Load().(*DocumentsState).documents {
+		if doc.Dirty {
+			return true
+		}
+	}
+	return false
+}
+
+func (vm *DocumentsViewModel) activate(state *DocumentsState, doc *Document) {
+	state.Active = doc.Id
+	state.Error = nil
+	doc.LastActive = time.Now().UnixMilli()
+	if _, ok := vm.viewers[doc.Id]; !ok {
+		vm.load(doc)
+	}
+	vm.evict(state)
+}
+
+func (vm *DocumentsViewModel) load(doc *Document) {
+	vm.app.Logger().Printf("Loading document %s (%s)", doc.Id, doc.Filename)
+	vm.attach(doc, NewViewerViewModelFromFileWith(vm.app, doc.Filename))
+}
+
+func (vm *DocumentsViewModel) attach(doc *Document, viewer *ViewerViewModel) {
+	vm.viewers[doc.Id] = viewer
+	doc.Loaded = true
+	// Observe first so a load finishing in between isn't missed; its update waits for this one
+	viewer.Observe("documents", &documentObserver{vm: vm, id: doc.Id})
+	doc.Dirty = viewer.CloneState().Dirty
+}
+
+func (vm *DocumentsViewModel) close(state *DocumentsState, doc *Document) {
+	if viewer, ok := vm.viewers[doc.Id]; ok {
+		viewer.Clear()
+	}
+	delete(vm.viewers, doc.Id)
+	state.remove(doc.Id)
+	if state.PendingClose == doc.Id {
+		state.PendingClose = ""
+	}
+	if state.Active == doc.Id {
+		state.Active = ""
+		// Fall back to the most recently shown remaining document
+		var next *Document
+		for _, other := range state.documents {
+			if next == nil || other.LastActive > next.LastActive {
+				next = other
+			}
+		}
+		if next != nil {
+			vm.activate(state, next)
+		}
+	}
+}
+
+// Drop the least recently shown clean documents until at most maxLoaded are in memory
+func (vm *DocumentsViewModel) evict(state *DocumentsState) {
+	for len(vm.viewers) > vm.maxLoaded {
+		var oldest *Document
+		for _, doc := range state.documents {
+			if !doc.Loaded || doc.Dirty || doc.Id == state.Active {
+				continue
+			}
+			if oldest == nil || doc.LastActive < oldest.LastActive {
+				oldest = doc
+			}
+		}
+		if oldest == nil {
+			// Everything left is dirty or active; unsaved changes are never evicted
+			return
+		}
+		vm.app.Logger().Printf("Evicting document %s (%s)", oldest.Id, oldest.Filename)
+		vm.viewers[oldest.Id].Clear()
+		delete(vm.viewers, oldest.Id)
+		oldest.Loaded = false
+	}
+}
+
+// Keeps a document's Dirty flag in step with its viewer. Updates may come from the viewer's load goroutine.
+type documentObserver struct {
+	vm *DocumentsViewModel
+	id string
+}
+
+func (o *documentObserver) Update(viewerState *MsgPackViewerState) {
+	o.vm.mu.Lock()
+	current := o.vm.state.Load().(*DocumentsState)
+	doc := current.Find(o.id)
+	if doc == nil || (doc.Dirty == viewerState.Dirty && doc.Filename == viewerState.Filename) {
+		o.vm.mu.Unlock()
+		return
+	}
+	state := current.Clone()
+	doc = state.Find(o.id)
+	doc.Dirty = viewerState.Dirty
+	if viewerState.Filename != "" && viewerState.Filename != doc.Filename {
+		// Save As moved the document
+		doc.Filename = viewerState.Filename
+		doc.Name = filepath.Base(viewerState.Filename)
+	}
+	o.vm.state.Store(state)
+	o.vm.mu.Unlock()
+	o.vm.notify()
+}
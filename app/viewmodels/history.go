@@ -0,0 +1,224 @@
+package viewmodels
+
+import (
+	"errors"
+	"sync/atomic"
+
+	"github.com/marcuswu/msgpack/app"
+	"github.com/marcuswu/msgpack/app/files"
+	"github.com/marcuswu/msgpack/app/logic"
+	"github.com/marcuswu/msgpack/mobile/viewmodel"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// The saved versions of filename, or nil when there is nowhere to keep them
+func (vm *ViewerViewModel) openHistory(filename string) *files.History {
+	if filename == "" || vm.app.StorageDir() == "" {
+		return nil
+	}
+	history := files.OpenHistory(vm.app.StorageDir(), filename)
+	history.SetMaxVersions(app.MaxVersions.GetFrom(vm.app.Config()))
+	return history
+}
+
+// Add a saved file to its history. Failures are logged; the save itself already succeeded.
+func (vm *ViewerViewModel) recordVersion(filename string, data []byte, format structEdFormat, message string) {
+	history := vm.openHistory(filename)
+	if history == nil {
+		return
+	}
+	if _, err := history.Record(data, int(format), message); err != nil {
+		vm.app.Logger().Printf("Failed to record version: %s\n", err.Error())
+	}
+}
+
+/*
+ViewModel for the version history of the open file
+history actions:
+* List saved versions
+* Select a version to see how the document has changed since
+* Restore a version into the editor
+*/
+type HistoryState struct {
+	Filename string
+	Versions *files.Versions `msgpack:"-"`
+	// Id of the selected version, "" when none is selected
+	Selected string
+	// Changes from the selected version to the current document
+	Diff  *logic.Diff `msgpack:"-"`
+	Error error       `msgpack:"-"`
+}
+
+func (s *HistoryState) Clone() *HistoryState {
+	return &HistoryState{Filename: s.Filename, Versions: s.Versions, Selected: s.Selected, Diff: s.Diff, Error: s.Error}
+}
+
+func (s *HistoryState) SelectedVersion() *files.Version {
+	if s.Versions == nil {
+		return nil
+	}
+	return s.Versions.Find(s.Selected)
+}
+
+type HistoryStateFunc interface {
+	WithState(*HistoryState)
+}
+type HistoryScreenFunc struct {
+	StateFunc func(*HistoryState)
+}
+
+func (sf *HistoryScreenFunc) WithState(state *HistoryState) {
+	sf.StateFunc(state)
+}
+
+type HistoryStateObserver interface {
+	Update(*HistoryState)
+}
+
+type HistoryViewModel struct {
+	viewmodel.Lifecycle
+	state     atomic.Value
+	observers map[string]HistoryStateObserver
+	viewer    *ViewerViewModel
+	history   *files.History
+}
+
+// History of the file open in the viewer; restoring a version replaces the viewer's document
+func (vm *ViewerViewModel) NewHistory() *HistoryViewModel {
+	filename := vm.state.Load().(*MsgPackViewerState).Filename
+	history := &HistoryViewModel{observers: make(map[string]HistoryStateObserver), viewer: vm, history: vm.openHistory(filename)}
+	history.UpdateState(&HistoryState{Filename: filename, Versions: &files.Versions{}})
+	history.Refresh()
+	return history
+}
+
+func (b *HistoryViewModel) UpdateState(newState *HistoryState) {
+	b.state.Store(newState)
+	b.Notify(func() {
+		for _, sub := range b.observers {
+			sub.Update(b.state.Load().(*HistoryState))
+		}
+	})
+}
+
+func (b *HistoryViewModel) CloneState() *HistoryState {
+	return b.state.Load().(*HistoryState).Clone()
+}
+
+func (b *HistoryViewModel) WithState(stateFunc HistoryStateFunc) {
+	stateFunc.WithState(b.state.Load().(*HistoryState))
+}
+
+func (b *HistoryViewModel) Observe(id string, callback HistoryStateObserver) {
+	b.observers[id] = callback
+}
+
+// Reload the version list, e.g. after the document was saved
+func (vm *HistoryViewModel) Refresh() {
+	state := vm.CloneState()
+	if vm.history == nil {
+		state.Error = errors.New("version history is only kept for saved files")
+		vm.UpdateState(state)
+		return
+	}
+	versions, err := vm.history.Versions()
+	state.Versions = versions
+	state.Error = err
+	if state.SelectedVersion() == nil {
+		state.Selected = ""
+		state.Diff = nil
+	}
+	vm.UpdateState(state)
+}
+
+// Select a version and compare it with the current document
+func (vm *HistoryViewModel) Select(id string) {
+	state := vm.CloneState()
+	version, _, err := vm.load(id)
+	if err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return
+	}
+	current := vm.viewer.state.Load().(*MsgPackViewerState)
+	if err := documentError(current); err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return
+	}
+	state.Selected = id
+	state.Diff = logic.Compare(version.Value(), current.Data.Value())
+	state.Error = nil
+	vm.UpdateState(state)
+}
+
+func (vm *HistoryViewModel) ClearSelection() {
+	state := vm.CloneState()
+	state.Selected = ""
+	state.Diff = nil
+	vm.UpdateState(state)
+}
+
+// Replace the viewer's document with a saved version. The restored document is unsaved.
+func (vm *HistoryViewModel) Restore(id string) {
+	// The version would be replaced by the document once it finishes loading
+	err := documentError(vm.viewer.state.Load().(*MsgPackViewerState))
+	var data *logic.Field
+	var format structEdFormat
+	if err == nil {
+		data, format, err = vm.load(id)
+	}
+	if err == nil {
+		vm.viewer.restoreVersion(data, format)
+		vm.Select(id)
+		return
+	}
+	state := vm.CloneState()
+	state.Error = err
+	vm.UpdateState(state)
+}
+
+// Decode version id
+func (vm *HistoryViewModel) load(id string) (*logic.Field, structEdFormat, error) {
+	if vm.history == nil {
+		return nil, unknownFormat, errors.New("version history is only kept for saved files")
+	}
+	fileData, err := vm.history.Load(id)
+	if err != nil {
+		return nil, unknownFormat, err
+	}
+	data, format, report := vm.viewer.decode(fileData)
+	if report != nil {
+		return nil, unknownFormat, report
+	}
+	return data, format, nil
+}
+
+func (vm *ViewerViewModel) restoreVersion(data *logic.Field, format structEdFormat) {
+	state := vm.CloneState()
+	state.Data = data
+	state.Load.Result = data
+	state.format = format
+	state.Error = nil
+	state.Dirty = true
+	vm.snapshotDraft(state)
+	vm.UpdateState(state)
+}
+
+func (vm *HistoryViewModel) SaveInstanceState() ([]byte, error) {
+	return msgpack.Marshal(vm.state.Load().(*HistoryState))
+}
+
+// Restore the selection; the versions and diff are read again
+func (vm *HistoryViewModel) RestoreInstanceState(data []byte) error {
+	newState := vm.CloneState()
+	if err := msgpack.Unmarshal(data, newState); err != nil {
+		return err
+	}
+	vm.UpdateState(newState)
+	vm.Refresh()
+	if selected := vm.state.Load().(*HistoryState).Selected; selected != "" {
+		vm.Select(selected)
+	}
+	return nil
+}
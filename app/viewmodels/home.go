@@ -1,12 +1,17 @@
 package viewmodels
 
 import (
-	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"sync/atomic"
 
 	"github.com/marcuswu/msgpack/app"
+	"github.com/marcuswu/msgpack/app/files"
+	"github.com/marcuswu/msgpack/app/logic"
+	"github.com/marcuswu/msgpack/mobile/router"
 	"github.com/marcuswu/msgpack/mobile/state"
+	"github.com/marcuswu/msgpack/mobile/viewmodel"
 )
 
 /*
@@ -14,14 +19,31 @@ ViewModel for home screen
 Home actions:
 * Open file selection
 * Select file
+* Reopen, pin or remove a recent file
+* Restore or discard unsaved changes from a session the app was killed during
 */
 type HomeState struct {
-	File  string
-	Error error
+	File     string
+	FileInfo *files.FileInfo
+	// Reloaded from storage rather than kept in saved instance state
+	Recent *files.RecentFiles `msgpack:"-"`
+	// Interrupted edit sessions on offer for recovery
+	Recovery *files.Sessions `msgpack:"-"`
+	// Set when File should be opened with RestoreViewerViewModel rather than read from disk.
+	// File and Restore are also passed to the viewer route as its arguments.
+	Restore bool
+	Error   error `msgpack:"-"`
 }
 
 func (s *HomeState) Clone() state.UIState {
-	return &HomeState{File: s.File, Error: s.Error}
+	return &HomeState{
+		File:     s.File,
+		FileInfo: s.FileInfo,
+		Recent:   s.Recent.Clone(),
+		Recovery: s.Recovery.Clone(),
+		Restore:  s.Restore,
+		Error:    s.Error,
+	}
 }
 
 // type HomeStateFunc func(*HomeState)
@@ -41,15 +63,19 @@ type HomeStateObserver interface {
 }
 
 type HomeViewModel struct {
+	viewmodel.Lifecycle
 	state     atomic.Value
 	observers map[string]HomeStateObserver
+	app       *app.Container
 }
 
 func (b *HomeViewModel) UpdateState(newState *HomeState) {
 	b.state.Store(newState)
-	for _, sub := range b.observers {
-		sub.Update(b.state.Load().(*HomeState))
-	}
+	b.Notify(func() {
+		for _, sub := range b.observers {
+			sub.Update(b.state.Load().(*HomeState))
+		}
+	})
 }
 
 func (b *HomeViewModel) CloneState() *HomeState {
@@ -64,19 +90,177 @@ func (b *HomeViewModel) Observe(id string, callback HomeStateObserver) {
 	b.observers[id] = callback
 }
 
+// Name of the recent files list within the container's StorageDir
+const recentFilesName = "recent_files.msgpack"
+
 func NewHomeViewModel() *HomeViewModel {
-	return &HomeViewModel{}
+	return NewHomeViewModelWith(app.Default())
+}
+
+func NewHomeViewModelWith(container *app.Container) *HomeViewModel {
+	container = containerOrDefault(container)
+	vm := &HomeViewModel{observers: make(map[string]HomeStateObserver), app: container}
+	state := &HomeState{Recent: files.NewRecentFiles(), Recovery: &files.Sessions{}}
+	if path := vm.recentFilesPath(); path != "" {
+		recent, err := files.LoadRecentFiles(path)
+		if err != nil {
+			vm.app.Logger().Printf("Failed to load recent files: %s\n", err.Error())
+		}
+		state.Recent = recent
+	}
+	if vm.app.StorageDir() != "" {
+		sessions, err := files.ListSessions(vm.app.StorageDir())
+		if err != nil {
+			vm.app.Logger().Printf("Failed to list interrupted sessions: %s\n", err.Error())
+		}
+		state.Recovery = sessions
+	}
+	vm.UpdateState(state)
+	vm.OnStart(vm.RefreshRecent)
+	return vm
+}
+
+func (vm *HomeViewModel) recentFilesPath() string {
+	if vm.app.StorageDir() == "" {
+		return ""
+	}
+	return filepath.Join(vm.app.StorageDir(), recentFilesName)
+}
+
+// Persist and publish a change to the recent files list
+func (vm *HomeViewModel) updateRecent(newState *HomeState) {
+	if path := vm.recentFilesPath(); path != "" {
+		if err := newState.Recent.Save(path); err != nil {
+			vm.app.Logger().Printf("Failed to save recent files: %s\n", err.Error())
+		}
+	}
+	vm.UpdateState(newState)
 }
 
 func (vm *HomeViewModel) FileSelected(file string) {
 	newState := vm.CloneState()
 	newState.File = file
+	newState.Restore = false
+	newState.Error = nil
 
-	if _, err := os.Stat(newState.File); errors.Is(err, os.ErrNotExist) {
+	provider := vm.app.FileProvider()
+	info, err := provider.Stat(file)
+	if err == nil && !info.Exists {
+		err = fmt.Errorf("%s: %w", file, os.ErrNotExist)
+	}
+	if err != nil {
 		// Show error
 		newState.Error = err
+		if entry := newState.Recent.Find(file); entry != nil {
+			entry.Missing = true
+		}
+		vm.UpdateState(newState)
+		return
+	}
+	newState.FileInfo = info
+	// Keep access to content uris across restarts; local paths don't need it
+	if err := provider.TakePersistablePermission(file); err != nil {
+		vm.app.Logger().Printf("Could not persist permission for %s: %s\n", file, err.Error())
+	}
+	newState.Recent.Opened(info, detectFormat(provider, file))
+	vm.updateRecent(newState)
+
+	vm.navigate(router.NewArgs().SetString(app.FileArg, file))
+}
+
+// Sniff the codec of a file from its first bytes
+func detectFormat(provider files.FileProvider, uri string) string {
+	reader, err := provider.Open(uri)
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+	prefix, err := reader.ReadChunk(64)
+	if err != nil {
+		return ""
+	}
+	return logic.DetectCodec(prefix)
+}
+
+func (vm *HomeViewModel) OpenRecent(uri string) {
+	vm.FileSelected(uri)
+}
+
+func (vm *HomeViewModel) RemoveRecent(uri string) {
+	newState := vm.CloneState()
+	newState.Recent.Remove(uri)
+	// Nothing will reopen it, so the platform doesn't need to hold on to access
+	if err := vm.app.FileProvider().ReleasePersistablePermission(uri); err != nil {
+		vm.app.Logger().Printf("Could not release permission for %s: %s\n", uri, err.Error())
+	}
+	vm.updateRecent(newState)
+}
+
+// Remove every recent file that isn't pinned
+func (vm *HomeViewModel) ClearRecent() {
+	newState := vm.CloneState()
+	newState.Recent.Clear()
+	vm.updateRecent(newState)
+}
+
+func (vm *HomeViewModel) PinRecent(uri string, pinned bool) {
+	newState := vm.CloneState()
+	newState.Recent.SetPinned(uri, pinned)
+	vm.updateRecent(newState)
+}
+
+// Check which recent files still exist, e.g. when the home screen is shown again
+func (vm *HomeViewModel) RefreshRecent() {
+	newState := vm.CloneState()
+	newState.Recent.Refresh(vm.app.FileProvider())
+	vm.UpdateState(newState)
+}
+
+func (vm *HomeViewModel) RemoveMissingRecent() {
+	newState := vm.CloneState()
+	newState.Recent.RemoveMissing()
+	vm.updateRecent(newState)
+}
+
+// Reopen uri with the unsaved changes recorded before the app was killed
+func (vm *HomeViewModel) RestoreSession(uri string) {
+	newState := vm.CloneState()
+	newState.File = uri
+	newState.Restore = true
+	newState.Error = nil
+	newState.Recovery.Remove(uri)
+	vm.UpdateState(newState)
+
+	vm.navigate(router.NewArgs().SetString(app.FileArg, uri).SetBool(app.RestoreArg, true))
+}
+
+// Open the viewer with args, showing why when they aren't valid
+func (vm *HomeViewModel) navigate(args *router.Args) {
+	if err := vm.app.Navigate(app.ViewerRoute, args); err != nil {
+		newState := vm.CloneState()
+		newState.Error = err
+		vm.UpdateState(newState)
 	}
+}
+
+func (vm *HomeViewModel) DiscardSession(uri string) {
+	newState := vm.CloneState()
+	if err := files.DiscardSession(vm.app.StorageDir(), uri); err != nil {
+		newState.Error = err
+	}
+	newState.Recovery.Remove(uri)
 	vm.UpdateState(newState)
+}
+
+func (vm *HomeViewModel) SaveInstanceState() ([]byte, error) {
+	return state.Marshal(vm.state.Load().(*HomeState))
+}
 
-	app.Router().Navigate("viewer")
+func (vm *HomeViewModel) RestoreInstanceState(data []byte) error {
+	newState := vm.CloneState()
+	if err := state.Unmarshal(data, newState); err != nil {
+		return err
+	}
+	vm.UpdateState(newState)
+	return nil
 }
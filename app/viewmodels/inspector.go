@@ -0,0 +1,208 @@
+package viewmodels
+
+import (
+	"bytes"
+	"sync/atomic"
+
+	"github.com/marcuswu/msgpack/app"
+	"github.com/marcuswu/msgpack/app/logic"
+	"github.com/marcuswu/msgpack/mobile/viewmodel"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+/*
+ViewModel for the msgpack hex inspector
+inspector actions:
+* Select a value in the tree (jumps to its bytes)
+* Select a byte in the hex view (jumps to its value)
+*/
+type InspectorState struct {
+	// Rebuilt from the document rather than kept in saved instance state
+	Tokens *logic.Inspection `msgpack:"-"`
+	// Index into Tokens of the selected token, -1 when nothing is selected
+	Selected     int
+	SelectedPath string
+	Error        error `msgpack:"-"`
+}
+
+func (s *InspectorState) Clone() *InspectorState {
+	return &InspectorState{Tokens: s.Tokens, Selected: s.Selected, SelectedPath: s.SelectedPath, Error: s.Error}
+}
+
+// Hex dump of the whole buffer
+func (s *InspectorState) HexDump() string {
+	if s.Tokens == nil {
+		return ""
+	}
+	return logic.HexDump(s.Tokens.Data(), 0)
+}
+
+func (s *InspectorState) SelectedToken() *logic.HexToken {
+	if s.Tokens == nil {
+		return nil
+	}
+	return s.Tokens.Get(s.Selected)
+}
+
+type HexStateFunc interface {
+	WithState(*InspectorState)
+}
+type InspectorStateFunc struct {
+	StateFunc func(*InspectorState)
+}
+
+func (sf *InspectorStateFunc) WithState(state *InspectorState) {
+	sf.StateFunc(state)
+}
+
+type InspectorStateObserver interface {
+	Update(*InspectorState)
+}
+
+type InspectorViewModel struct {
+	viewmodel.Lifecycle
+	state     atomic.Value
+	observers map[string]InspectorStateObserver
+	app       *app.Container
+}
+
+func NewInspectorViewModel(fileData []byte) *InspectorViewModel {
+	return NewInspectorViewModelWith(app.Default(), fileData)
+}
+
+func NewInspectorViewModelWith(container *app.Container, fileData []byte) *InspectorViewModel {
+	vm := &InspectorViewModel{observers: make(map[string]InspectorStateObserver), app: containerOrDefault(container)}
+	tokens, err := logic.InspectMsgPack(fileData)
+	if err != nil {
+		vm.app.Logger().Printf("Failed to inspect msgpack data: %s\n", err.Error())
+	}
+	vm.UpdateState(&InspectorState{Tokens: tokens, Selected: -1, Error: err})
+	return vm
+}
+
+/*
+Inspect the document's bytes. A msgpack document is inspected as the file was loaded
+or last saved, so key order, int widths and str/bin families are the file's own;
+edits show once saved. JSON and YAML documents are inspected as they would be
+written in msgpack.
+*/
+func (vm *ViewerViewModel) NewInspector() *InspectorViewModel {
+	var data []byte
+	var err error
+	vm.WithState(&ViewerStateFunc{
+		StateFunc: func(state *MsgPackViewerState) {
+			if err = documentError(state); err != nil {
+				return
+			}
+			if state.format == msgpackFormat && len(state.fileData()) > 0 {
+				data = state.fileData()
+				return
+			}
+			var buf bytes.Buffer
+			enc := msgpack.NewEncoder(&buf)
+			enc.SetSortMapKeys(true)
+			err = enc.Encode(state.Data.Value())
+			data = buf.Bytes()
+		},
+	})
+	if err != nil {
+		inspector := &InspectorViewModel{observers: make(map[string]InspectorStateObserver), app: vm.app}
+		inspector.UpdateState(&InspectorState{Selected: -1, Error: err})
+		return inspector
+	}
+	return NewInspectorViewModelWith(vm.app, data)
+}
+
+func (b *InspectorViewModel) UpdateState(newState *InspectorState) {
+	b.state.Store(newState)
+	b.Notify(func() {
+		for _, sub := range b.observers {
+			sub.Update(b.state.Load().(*InspectorState))
+		}
+	})
+}
+
+func (b *InspectorViewModel) CloneState() *InspectorState {
+	return b.state.Load().(*InspectorState).Clone()
+}
+
+func (b *InspectorViewModel) WithState(stateFunc HexStateFunc) {
+	stateFunc.WithState(b.state.Load().(*InspectorState))
+}
+
+func (b *InspectorViewModel) Observe(id string, callback InspectorStateObserver) {
+	b.observers[id] = callback
+}
+
+// Tree -> hex: select the token for the value at path
+func (vm *InspectorViewModel) SelectPath(path string) {
+	state := vm.CloneState()
+	if state.Tokens == nil {
+		return
+	}
+	index := state.Tokens.IndexForPath(path)
+	if index < 0 {
+		state.Error = logic.ErrNotFound
+		vm.UpdateState(state)
+		return
+	}
+	state.Selected = index
+	state.SelectedPath = path
+	state.Error = nil
+	vm.UpdateState(state)
+}
+
+// Hex -> tree: select the token containing the byte at offset
+func (vm *InspectorViewModel) SelectOffset(offset int) {
+	state := vm.CloneState()
+	if state.Tokens == nil {
+		return
+	}
+	index := state.Tokens.IndexAtOffset(offset)
+	if index < 0 {
+		state.Selected = -1
+		state.SelectedPath = ""
+		vm.UpdateState(state)
+		return
+	}
+	state.Selected = index
+	state.SelectedPath = state.Tokens.Get(index).Path
+	state.Error = nil
+	vm.UpdateState(state)
+}
+
+// Move the selection to the next or previous token (delta may be negative)
+func (vm *InspectorViewModel) Step(delta int) {
+	state := vm.CloneState()
+	if state.Tokens == nil || state.Tokens.Size() == 0 {
+		return
+	}
+	index := state.Selected + delta
+	if index < 0 {
+		index = 0
+	}
+	if index >= state.Tokens.Size() {
+		index = state.Tokens.Size() - 1
+	}
+	state.Selected = index
+	state.SelectedPath = state.Tokens.Get(index).Path
+	vm.UpdateState(state)
+}
+
+// InspectorState.Clone doesn't return a state.UIState, so it is encoded directly
+func (vm *InspectorViewModel) SaveInstanceState() ([]byte, error) {
+	return msgpack.Marshal(vm.state.Load().(*InspectorState))
+}
+
+// Restore the selection; the tokens come from the document the inspector was created with
+func (vm *InspectorViewModel) RestoreInstanceState(data []byte) error {
+	newState := vm.CloneState()
+	if err := msgpack.Unmarshal(data, newState); err != nil {
+		return err
+	}
+	if newState.Tokens == nil || newState.Tokens.Get(newState.Selected) == nil {
+		newState.Selected = -1
+	}
+	vm.UpdateState(newState)
+	return nil
+}
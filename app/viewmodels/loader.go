@@ -0,0 +1,192 @@
+package viewmodels
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/marcuswu/msgpack/app/files"
+	"github.com/marcuswu/msgpack/app/logic"
+	"github.com/marcuswu/msgpack/mobile/service"
+)
+
+// Reads the bytes of a document, reporting bytes read so far and the total (-1 when unknown)
+type readFunc func(ctx context.Context, progress func(read int64, total int64)) ([]byte, error)
+
+/*
+Builds the state for a document from the bytes read, or from err when they couldn't be.
+It runs in the background and may touch the file system; nil publishes nothing.
+*/
+type buildFunc func(ctx context.Context, fileData []byte, err error) *MsgPackViewerState
+
+/*
+viewerLoad tracks the load running in the background, if any.
+Each load gets a new id so that a load which was cancelled or replaced can't
+publish its progress or result over a newer one. States are stored while mu is held
+so that check holds, but observers are notified after it is released: they may
+start or cancel a load themselves.
+*/
+type viewerLoad struct {
+	mu     sync.Mutex
+	id     int
+	cancel context.CancelFunc
+}
+
+// Loading state of the document, one of service.Loading, service.Success or service.Error
+func (s *MsgPackViewerState) LoadState() int {
+	return int(s.Load.State)
+}
+
+func (s *MsgPackViewerState) IsLoading() bool {
+	return s.Load.State == service.Loading
+}
+
+// Whether the last load stopped because it was cancelled rather than because it failed
+func (s *MsgPackViewerState) LoadCancelled() bool {
+	return s.Load.State == service.Error && errors.Is(s.Load.Error, context.Canceled)
+}
+
+// Fraction of the file read, from 0 to 1, or -1 when the size of the file isn't known
+func (s *MsgPackViewerState) Progress() float64 {
+	if s.BytesTotal < 0 {
+		return -1
+	}
+	if s.BytesTotal == 0 {
+		return 1
+	}
+	return float64(s.BytesRead) / float64(s.BytesTotal)
+}
+
+// Load filename in the background, replacing the current document once it has been decoded
+func (vm *ViewerViewModel) LoadFile(filename string) {
+	vm.load(filename, vm.fileReader(filename), vm.decodeFile(filename))
+}
+
+// Read filename through the container's FileProvider
+func (vm *ViewerViewModel) fileReader(filename string) readFunc {
+	return func(ctx context.Context, progress func(int64, int64)) ([]byte, error) {
+		return files.ReadAllContext(ctx, vm.app.FileProvider(), filename, progress)
+	}
+}
+
+// Hand back fileData, for documents opened from bytes
+func bytesReader(fileData []byte) readFunc {
+	return func(ctx context.Context, progress func(int64, int64)) ([]byte, error) {
+		progress(int64(len(fileData)), int64(len(fileData)))
+		return fileData, nil
+	}
+}
+
+/*
+Decode what was read as the document, the usual end of a load. A failed read becomes
+the state's Error, and nil is returned when ctx was cancelled while decoding.
+*/
+func (vm *ViewerViewModel) decodeFile(filename string) buildFunc {
+	return func(ctx context.Context, fileData []byte, err error) *MsgPackViewerState {
+		if err != nil {
+			vm.app.Logger().Printf("Failed to read file: %s\n", err.Error())
+			return &MsgPackViewerState{Filename: filename, Error: err, Load: service.Resource[*logic.Field]{State: service.Error, Error: err}, BytesTotal: -1}
+		}
+		data, format, err := vm.decodeContext(ctx, fileData)
+		if ctx.Err() != nil {
+			return nil
+		}
+		report, _ := err.(*logic.DecodeReport)
+		return vm.decodedState(filename, fileData, data, format, report)
+	}
+}
+
+// Stop the load in progress, e.g. when the user backs out of the screen. The state's Load ends in context.Canceled.
+func (vm *ViewerViewModel) CancelLoad() {
+	vm.loading.mu.Lock()
+	if !vm.stopLoad() {
+		vm.loading.mu.Unlock()
+		return
+	}
+	vm.app.Logger().Printf("Cancelled loading document")
+
+	state := vm.CloneState()
+	state.Load = service.Resource[*logic.Field]{State: service.Error, Error: context.Canceled}
+	vm.storeState(state)
+	vm.loading.mu.Unlock()
+	vm.notify()
+}
+
+/*
+Read a document in the background, then publish the state build makes of it.
+Until then the state is Loading, and a load started in the meantime replaces this one.
+*/
+func (vm *ViewerViewModel) load(filename string, read readFunc, build buildFunc) {
+	ctx, cancel := context.WithCancel(vm.Context())
+	vm.loading.mu.Lock()
+	vm.stopLoad()
+	vm.loading.cancel = cancel
+	id := vm.loading.id
+	vm.storeState(&MsgPackViewerState{Filename: filename, Load: service.Resource[*logic.Field]{State: service.Loading}, BytesTotal: -1})
+	vm.loading.mu.Unlock()
+	vm.notify()
+
+	vm.Go(func(context.Context) {
+		defer cancel()
+		// Reads go through the runner so the platform's ServiceCallback can follow them
+		task := vm.reads.Run(ctx, filename, func(ctx context.Context) ([]byte, error) {
+			return read(ctx, func(read int64, total int64) {
+				vm.loadProgress(id, read, total)
+			})
+		}, nil)
+		resource := task.Wait()
+		if ctx.Err() != nil {
+			// Cancelled loads never publish, so there is nothing to finish
+			return
+		}
+		state := build(ctx, resource.Result, resource.Error)
+		if state == nil {
+			return
+		}
+		vm.finishLoad(id, state)
+	})
+}
+
+// Cancel any running load so its result is never published. Callers hold vm.loading.mu.
+func (vm *ViewerViewModel) stopLoad() bool {
+	vm.loading.id++
+	if vm.loading.cancel == nil {
+		return false
+	}
+	vm.loading.cancel()
+	vm.loading.cancel = nil
+	return true
+}
+
+// Publish read progress, at most once per percent when the size is known
+func (vm *ViewerViewModel) loadProgress(id int, read int64, total int64) {
+	vm.loading.mu.Lock()
+	if id != vm.loading.id {
+		vm.loading.mu.Unlock()
+		return
+	}
+	current := vm.state.Load().(*MsgPackViewerState)
+	if total > 0 && read < total && read*100/total == current.BytesRead*100/total {
+		vm.loading.mu.Unlock()
+		return
+	}
+	state := current.Clone()
+	state.BytesRead = read
+	state.BytesTotal = total
+	vm.storeState(state)
+	vm.loading.mu.Unlock()
+	vm.notify()
+}
+
+// Publish the outcome of load id unless it was cancelled or replaced in the meantime
+func (vm *ViewerViewModel) finishLoad(id int, state *MsgPackViewerState) {
+	vm.loading.mu.Lock()
+	if id != vm.loading.id {
+		vm.loading.mu.Unlock()
+		return
+	}
+	vm.loading.cancel = nil
+	vm.storeState(state)
+	vm.loading.mu.Unlock()
+	vm.notify()
+}
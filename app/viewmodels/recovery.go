@@ -0,0 +1,303 @@
+package viewmodels
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/marcuswu/msgpack/app"
+	"github.com/marcuswu/msgpack/app/files"
+	"github.com/marcuswu/msgpack/app/logic"
+	"github.com/marcuswu/msgpack/mobile/service"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Journal edits to filename in app-private storage, or nil when there is nowhere to keep them
+func (vm *ViewerViewModel) openJournal(filename string) *files.Journal {
+	if filename == "" || vm.app.StorageDir() == "" {
+		return nil
+	}
+	return files.OpenJournal(vm.app.StorageDir(), filename)
+}
+
+func draftOf(state *MsgPackViewerState) (*files.Draft, error) {
+	data, err := logic.EncodeMsgPack(state.Data.Value())
+	if err != nil {
+		return nil, err
+	}
+	return &files.Draft{Format: int(state.format), Data: data}, nil
+}
+
+// data as it would be after being saved in format and loaded again
+func normalizeDocument(data *logic.Field, format structEdFormat) (*logic.Field, error) {
+	var encoded []byte
+	var err error
+	codec := logic.MsgPackCodec
+	switch format {
+	case jsonFormat:
+		codec = logic.JsonCodec
+		encoded, err = logic.EncodeJson(data.Value())
+	case yamlFormat:
+		codec = logic.YamlCodec
+		encoded, err = logic.EncodeYaml(data.Value())
+	default:
+		encoded, err = logic.EncodeMsgPack(data.Value())
+	}
+	if err != nil {
+		return nil, err
+	}
+	return logic.DecodeAny(codec, encoded)
+}
+
+/*
+recordEdit writes an edit to the journal before it is published.
+previous is the state before the edit; it becomes the draft when this is the first unsaved edit.
+Journal failures are logged rather than failing the edit.
+*/
+func (vm *ViewerViewModel) recordEdit(previous *MsgPackViewerState, current *MsgPackViewerState, entry *files.JournalEntry) {
+	journal := current.journal()
+	if journal == nil {
+		return
+	}
+	if !journal.Active() {
+		draft, err := draftOf(previous)
+		if err == nil {
+			err = journal.Start(draft)
+		}
+		if err != nil {
+			vm.app.Logger().Printf("Failed to start edit journal: %s\n", err.Error())
+			return
+		}
+	}
+	if err := journal.Append(entry); err != nil {
+		vm.app.Logger().Printf("Failed to journal edit: %s\n", err.Error())
+		return
+	}
+	if journal.ShouldSnapshot() {
+		draft, err := draftOf(current)
+		if err == nil {
+			err = journal.Snapshot(draft)
+		}
+		if err != nil {
+			vm.app.Logger().Printf("Failed to snapshot draft: %s\n", err.Error())
+		}
+	}
+}
+
+// Replace the journaled draft with the whole of state, for changes that aren't single edits
+func (vm *ViewerViewModel) snapshotDraft(state *MsgPackViewerState) {
+	journal := state.journal()
+	if journal == nil {
+		return
+	}
+	draft, err := draftOf(state)
+	if err == nil && !journal.Active() {
+		err = journal.Start(draft)
+	} else if err == nil {
+		err = journal.Snapshot(draft)
+	}
+	if err != nil {
+		vm.app.Logger().Printf("Failed to snapshot draft: %s\n", err.Error())
+	}
+}
+
+// Remove state's unsaved changes from the edit journal, leaving the document itself as it is
+func (vm *ViewerViewModel) discardJournal(state *MsgPackViewerState) {
+	if journal := state.journal(); journal != nil {
+		if err := journal.Discard(); err != nil {
+			vm.app.Logger().Printf("Failed to discard edit journal: %s\n", err.Error())
+		}
+	}
+}
+
+// Throw away unsaved changes, removing them from the edit journal and going back to the document as last saved
+func (vm *ViewerViewModel) DiscardChanges() {
+	current := vm.state.Load().(*MsgPackViewerState)
+	if current.Filename != "" {
+		vm.ReloadFromDisk()
+		return
+	}
+	// Documents opened from bytes have nowhere to be saved, so go back to the bytes they were opened with
+	vm.discardJournal(current)
+	vm.load("", bytesReader(current.fileData()), vm.decodeFile(""))
+}
+
+// Open the unsaved session for uri that was interrupted when the app was last killed
+func RestoreViewerViewModel(uri string) *ViewerViewModel {
+	return RestoreViewerViewModelWith(app.Default(), uri)
+}
+
+func RestoreViewerViewModelWith(container *app.Container, uri string) *ViewerViewModel {
+	vm := newViewerViewModel(container)
+	vm.restoreSession(uri)
+	return vm
+}
+
+// Restore the session of uri in the background, reading the file as it is now to save and merge against
+func (vm *ViewerViewModel) restoreSession(uri string) {
+	vm.load(uri, vm.fileReader(uri), func(ctx context.Context, fileData []byte, err error) *MsgPackViewerState {
+		return vm.focusState(vm.restoreSessionState(uri, vm.restoredSource(uri, fileData, err)))
+	})
+}
+
+/*
+The source of a document restored from a draft rather than decoded from its file: the file
+as read now, with its disk version so saving still notices external changes. When the file
+couldn't be read (readErr) nothing is known of it and the first save reports a conflict.
+*/
+func (vm *ViewerViewModel) restoredSource(filename string, fileData []byte, readErr error) *documentSource {
+	source := &documentSource{fileData: fileData, journal: vm.openJournal(filename)}
+	if filename == "" {
+		return source
+	}
+	if readErr == nil {
+		data, _, _ := vm.decode(fileData)
+		source.disk = vm.diskVersionOf(filename, fileData, data)
+	}
+	if source.disk == nil {
+		vm.app.Logger().Printf("Could not read %s as it is on disk; saving will report a conflict\n", filename)
+		source.disk = unknownDisk()
+	}
+	return source
+}
+
+// Rebuild the state of uri from its journaled draft and edits, on top of source
+func (vm *ViewerViewModel) restoreSessionState(uri string, source *documentSource) *MsgPackViewerState {
+	state := &MsgPackViewerState{Filename: uri, BytesTotal: -1}
+
+	draft, entries, err := files.LoadSession(vm.app.StorageDir(), uri)
+	if err == nil {
+		state.Data, err = logic.DecodeAny(logic.MsgPackCodec, draft.Data)
+	}
+	if err != nil {
+		vm.app.Logger().Printf("Failed to restore session: %s\n", err.Error())
+		state.Error = fmt.Errorf("could not restore unsaved changes: %w", err)
+		state.Load = service.Resource[*logic.Field]{State: service.Error, Error: state.Error}
+		return state
+	}
+	state.format = structEdFormat(draft.Format)
+
+	for i, entry := range entries {
+		switch entry.Op {
+		case files.JournalSet:
+			err = setStatePath(state, entry.Path, logic.NewFieldWithValue(entry.Key, entry.Value))
+		case files.JournalFormat:
+			state.format = structEdFormat(entry.Format)
+		}
+		if err != nil {
+			// Keep what replayed cleanly; the rest of the log can't be trusted
+			vm.app.Logger().Printf("Failed to replay edit %d: %s\n", i, err.Error())
+			state.Error = fmt.Errorf("some unsaved changes could not be restored: %w", err)
+			break
+		}
+	}
+	// Drafts and edits are journaled as msgpack, so bring values back to the types the document's own codec gives them
+	if normalized, err := normalizeDocument(state.Data, state.format); err != nil {
+		vm.app.Logger().Printf("Failed to normalize restored document: %s\n", err.Error())
+	} else {
+		state.Data = normalized
+	}
+	state.Dirty = true
+	state.Load = service.Resource[*logic.Field]{State: service.Success, Result: state.Data}
+
+	// Collapse the replayed edits into a fresh draft so new edits aren't appended after any that failed
+	state.source = source
+	if journal := state.journal(); journal != nil {
+		draft, err := draftOf(state)
+		if err == nil {
+			err = journal.Snapshot(draft)
+		}
+		if err != nil {
+			vm.app.Logger().Printf("Failed to snapshot restored draft: %s\n", err.Error())
+		}
+	}
+	return state
+}
+
+/*
+viewerSnapshot is what the viewer keeps in saved instance state.
+The document itself is too large for an Android bundle, so it is reloaded from the file,
+or from the edit journal when there were unsaved changes. Draft only carries the
+document when there is no journal to recover it from.
+*/
+type viewerSnapshot struct {
+	Filename  string `msgpack:"filename"`
+	Format    int    `msgpack:"format"`
+	Dirty     bool   `msgpack:"dirty"`
+	Salvaged  bool   `msgpack:"salvaged"`
+	LastSaved int64  `msgpack:"last_saved"`
+	Draft     []byte `msgpack:"draft,omitempty"`
+}
+
+func (vm *ViewerViewModel) SaveInstanceState() ([]byte, error) {
+	current := vm.state.Load().(*MsgPackViewerState)
+	snapshot := &viewerSnapshot{
+		Filename:  current.Filename,
+		Format:    int(current.format),
+		Dirty:     current.Dirty,
+		Salvaged:  current.Salvaged,
+		LastSaved: current.LastSaved,
+	}
+	if current.Data != nil && (current.Filename == "" || (current.Dirty && current.journal() == nil)) {
+		data, err := logic.EncodeMsgPack(current.Data.Value())
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Draft = data
+	}
+	return msgpack.Marshal(snapshot)
+}
+
+/*
+Restore the viewer from SaveInstanceState. The document is rebuilt in the background like
+a load: a draft or journaled session is restored on top of the file as it is now, anything
+else is decoded from the file again.
+*/
+func (vm *ViewerViewModel) RestoreInstanceState(data []byte) error {
+	snapshot := &viewerSnapshot{}
+	if err := msgpack.Unmarshal(data, snapshot); err != nil {
+		return err
+	}
+	format := structEdFormat(snapshot.Format)
+	var draft *logic.Field
+	if snapshot.Draft != nil {
+		var err error
+		draft, err = logic.DecodeAny(logic.MsgPackCodec, snapshot.Draft)
+		if err == nil {
+			draft, err = normalizeDocument(draft, format)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	read := vm.fileReader(snapshot.Filename)
+	if snapshot.Filename == "" {
+		// Documents opened from bytes have no file; the draft is all there is
+		read = bytesReader(snapshot.Draft)
+	}
+	// The restored state replaces whatever the constructor started loading
+	vm.load(snapshot.Filename, read, func(ctx context.Context, fileData []byte, err error) *MsgPackViewerState {
+		var state *MsgPackViewerState
+		switch {
+		case draft != nil:
+			source := vm.restoredSource(snapshot.Filename, fileData, err)
+			state = &MsgPackViewerState{Filename: snapshot.Filename, Data: draft, Dirty: snapshot.Dirty, format: format, source: source}
+			state.Load = service.Resource[*logic.Field]{State: service.Success, Result: draft}
+			state.BytesRead = int64(len(fileData))
+			state.BytesTotal = int64(len(fileData))
+		case snapshot.Dirty && vm.app.StorageDir() != "":
+			state = vm.restoreSessionState(snapshot.Filename, vm.restoredSource(snapshot.Filename, fileData, err))
+		default:
+			if state = vm.decodeFile(snapshot.Filename)(ctx, fileData, err); state == nil {
+				return nil
+			}
+			if snapshot.Salvaged {
+				state = vm.salvagedState(state)
+			}
+			state.format = format
+		}
+		state.LastSaved = snapshot.LastSaved
+		return state
+	})
+	return nil
+}
@@ -1,25 +1,89 @@
 package viewmodels
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/marcuswu/msgpack/app"
+	"github.com/marcuswu/msgpack/app/files"
 	"github.com/marcuswu/msgpack/app/firebase"
+	"github.com/marcuswu/msgpack/mobile/service"
 	"github.com/marcuswu/msgpack/mobile/state"
+	"github.com/marcuswu/msgpack/mobile/viewmodel"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
+// Where the config values in use came from
+const (
+	// Nothing has ever been fetched; the built-in defaults are in use
+	ConfigDefaults = iota
+	// The fetch failed; the values activated by an earlier run are in use
+	ConfigCached
+	// Fresh values were fetched and activated
+	ConfigRemote
+)
+
+var (
+	ErrConfigTimeout     = errors.New("timed out fetching remote config")
+	ErrConfigFetchFailed = errors.New("could not fetch remote config")
+)
+
+// How long to wait for the platform to answer FetchAndActivate by default
+const defaultConfigFetchTimeout = 10 * time.Second
+
+// A failed fetch is tried once more before falling back; a timed out one isn't, the user has waited long enough
+var configFetchRetry = service.RetryPolicy{Attempts: 2, InitialDelay: time.Second, MaxDelay: time.Second, Multiplier: 1, Jitter: 0.2}
+
+// Key of the config fetch, as reported to the container's ServiceCallback
+const configFetchKey = "remote_config"
+
+// Name of the remote config status within the container's StorageDir
+const remoteConfigStatusName = "remote_config.msgpack"
+
+// What is remembered between runs about the remote config. LastActivated is unix milliseconds.
+type remoteConfigStatus struct {
+	LastActivated int64 `msgpack:"last_activated"`
+}
+
 /*
 ViewModel for splash screen
 Startup action:
 * load remote config
 * proceed to home screen
+* when the config can't be fetched: retry, or continue with the defaults
 */
 type StartupState struct {
 	HaveConfig bool
+	Fetching   bool `msgpack:"-"`
+	// Set when the last fetch failed or timed out; ConfigSource says what is used instead
+	Offline      bool
+	ConfigSource int
+	// When remote values were last activated (unix milliseconds), 0 if never
+	LastActivated int64
+	Error         error `msgpack:"-"`
 }
 
 func (s *StartupState) Clone() state.UIState {
-	return &StartupState{HaveConfig: s.HaveConfig}
+	return &StartupState{
+		HaveConfig:    s.HaveConfig,
+		Fetching:      s.Fetching,
+		Offline:       s.Offline,
+		ConfigSource:  s.ConfigSource,
+		LastActivated: s.LastActivated,
+		Error:         s.Error,
+	}
+}
+
+func (s *StartupState) ErrorMessage() string {
+	if s.Error == nil {
+		return ""
+	}
+	return s.Error.Error()
 }
 
 // type SplashStateFunc func(*StartupState)
@@ -39,22 +103,70 @@ type SplashStateObserver interface {
 }
 
 type SplashViewModel struct {
-	state     atomic.Value
-	observers map[string]SplashStateObserver
+	viewmodel.Lifecycle
+	// Held while the state is read, changed and stored; fetch results arrive on the runner's goroutine
+	mu          sync.Mutex
+	state       atomic.Value
+	observersMu sync.RWMutex
+	observers   map[string]SplashStateObserver
+	fetches     *service.Runner[bool]
+	fetch       configFetch
+	app         *app.Container
+}
+
+type configFetch struct {
+	mu      sync.Mutex
+	timeout time.Duration
 }
 
 func NewSplashViewModel() *SplashViewModel {
-	vm := &SplashViewModel{}
+	return NewSplashViewModelWith(app.Default())
+}
+
+func NewSplashViewModelWith(container *app.Container) *SplashViewModel {
+	container = containerOrDefault(container)
+	vm := &SplashViewModel{observers: make(map[string]SplashStateObserver), app: container}
+	vm.fetch.timeout = defaultConfigFetchTimeout
+	vm.fetches = service.NewRunner[bool](configFetchRetry)
+	vm.fetches.SetCallback(container.ServiceCallback())
 	state := &StartupState{}
+	if status := vm.loadRemoteConfigStatus(); status.LastActivated > 0 {
+		state.ConfigSource = ConfigCached
+		state.LastActivated = status.LastActivated
+	}
 	vm.UpdateState(state)
 	return vm
 }
 
 func (b *SplashViewModel) UpdateState(newState *StartupState) {
+	b.mu.Lock()
 	b.state.Store(newState)
-	for _, sub := range b.observers {
-		sub.Update(b.state.Load().(*StartupState))
-	}
+	b.mu.Unlock()
+	b.notify()
+}
+
+// Apply change to a copy of the state and publish it, one change at a time
+func (b *SplashViewModel) update(change func(*StartupState)) {
+	b.mu.Lock()
+	newState := b.CloneState()
+	change(newState)
+	b.state.Store(newState)
+	b.mu.Unlock()
+	b.notify()
+}
+
+func (b *SplashViewModel) notify() {
+	b.Notify(func() {
+		b.observersMu.RLock()
+		observers := make([]SplashStateObserver, 0, len(b.observers))
+		for _, sub := range b.observers {
+			observers = append(observers, sub)
+		}
+		b.observersMu.RUnlock()
+		for _, sub := range observers {
+			sub.Update(b.state.Load().(*StartupState))
+		}
+	})
 }
 
 func (b *SplashViewModel) CloneState() *StartupState {
@@ -66,24 +178,164 @@ func (b *SplashViewModel) WithState(stateFunc SplashStateFunc) {
 }
 
 func (b *SplashViewModel) Observe(id string, callback SplashStateObserver) {
+	b.observersMu.Lock()
+	defer b.observersMu.Unlock()
 	b.observers[id] = callback
 }
 
+// How long LoadRemoteConfig waits for the platform before falling back
+func (s *SplashViewModel) SetFetchTimeout(millis int) {
+	s.fetch.mu.Lock()
+	defer s.fetch.mu.Unlock()
+	s.fetch.timeout = time.Duration(millis) * time.Millisecond
+}
+
+/*
+LoadRemoteConfig fetches and activates the remote config, then moves on to the home screen.
+The platform passes false to the callback when the fetch failed. On failure or timeout the
+values activated by an earlier run are used and startup continues; when there are none
+the screen waits for Retry or ContinueOffline.
+A call while a fetch is running waits for that fetch rather than starting another.
+The fetch is dropped when the screen is cleared.
+*/
 func (s *SplashViewModel) LoadRemoteConfig() {
-	app.Config().FetchAndActivate(&firebase.ActivateCallback{Callback: func(bool) {
-		newState := s.CloneState()
-		newState.HaveConfig = true
-		s.UpdateState(newState)
-		s.CheckNavigate()
+	s.fetch.mu.Lock()
+	timeout := s.fetch.timeout
+	s.fetch.mu.Unlock()
+
+	s.update(func(newState *StartupState) {
+		newState.Fetching = true
+		newState.Error = nil
+	})
+
+	config := s.app.Config()
+	if config == nil {
+		s.configFetched(errors.New("no remote config has been set"))
+		return
+	}
+	s.fetches.Run(s.Context(), configFetchKey, func(ctx context.Context) (bool, error) {
+		return fetchConfig(ctx, config, timeout)
+	}, func(resource service.Resource[bool]) {
+		if resource.State != service.Loading {
+			s.configFetched(resource.Error)
+		}
+	})
+}
+
+// Ask the platform to fetch and activate config, giving up after timeout
+func fetchConfig(ctx context.Context, config firebase.RemoteConfig, timeout time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	// Buffered so a callback arriving after the timeout doesn't block the platform
+	fetched := make(chan bool, 1)
+	config.FetchAndActivate(&firebase.ActivateCallback{Callback: func(ok bool) {
+		fetched <- ok
 	}})
+	select {
+	case ok := <-fetched:
+		if !ok {
+			return false, ErrConfigFetchFailed
+		}
+		return true, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return false, service.Permanent(ErrConfigTimeout)
+		}
+		return false, ctx.Err()
+	}
+}
+
+// Try fetching the remote config again after a failure
+func (s *SplashViewModel) Retry() {
+	s.LoadRemoteConfig()
+}
+
+// Carry on with the built-in defaults after a failed fetch
+func (s *SplashViewModel) ContinueOffline() {
+	s.update(func(newState *StartupState) {
+		newState.HaveConfig = true
+	})
+	s.CheckNavigate()
+}
+
+// Record the outcome of the fetch. Called on the runner's goroutine.
+func (s *SplashViewModel) configFetched(err error) {
+	if err != nil {
+		s.app.Logger().Printf("Failed to load remote config: %s\n", err.Error())
+	}
+	var activated int64
+	s.update(func(newState *StartupState) {
+		newState.Fetching = false
+		newState.Error = err
+		newState.Offline = err != nil
+		if err == nil {
+			newState.HaveConfig = true
+			newState.ConfigSource = ConfigRemote
+			newState.LastActivated = time.Now().UnixMilli()
+			activated = newState.LastActivated
+			return
+		}
+		newState.ConfigSource = ConfigDefaults
+		if newState.LastActivated > 0 {
+			newState.ConfigSource = ConfigCached
+			newState.HaveConfig = true
+		}
+	})
+	if activated > 0 {
+		s.saveRemoteConfigStatus(&remoteConfigStatus{LastActivated: activated})
+	}
+	s.CheckNavigate()
 }
 
+// Move on to home once there is config to use. Home replaces splash so back doesn't return to it.
 func (s *SplashViewModel) CheckNavigate() {
 	s.WithState(&StartupStateFunc{
 		stateFunc: func(ss *StartupState) {
 			if ss.HaveConfig {
-				app.Router().Navigate("home")
+				if err := s.app.Replace(app.HomeRoute, nil); err != nil {
+					s.app.Logger().Printf("Could not navigate home: %s\n", err.Error())
+				}
 			}
 		},
 	})
 }
+
+func (vm *SplashViewModel) SaveInstanceState() ([]byte, error) {
+	return state.Marshal(vm.state.Load().(*StartupState))
+}
+
+func (vm *SplashViewModel) RestoreInstanceState(data []byte) error {
+	var err error
+	vm.update(func(newState *StartupState) {
+		err = state.Unmarshal(data, newState)
+	})
+	return err
+}
+
+func (vm *SplashViewModel) loadRemoteConfigStatus() *remoteConfigStatus {
+	status := &remoteConfigStatus{}
+	if vm.app.StorageDir() == "" {
+		return status
+	}
+	data, err := os.ReadFile(filepath.Join(vm.app.StorageDir(), remoteConfigStatusName))
+	if err == nil {
+		err = msgpack.Unmarshal(data, status)
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		vm.app.Logger().Printf("Failed to read remote config status: %s\n", err.Error())
+	}
+	return status
+}
+
+func (vm *SplashViewModel) saveRemoteConfigStatus(status *remoteConfigStatus) {
+	if vm.app.StorageDir() == "" {
+		return
+	}
+	data, err := msgpack.Marshal(status)
+	if err == nil {
+		err = files.WriteAtomic(filepath.Join(vm.app.StorageDir(), remoteConfigStatusName), data, 0)
+	}
+	if err != nil {
+		vm.app.Logger().Printf("Failed to save remote config status: %s\n", err.Error())
+	}
+}
@@ -1,15 +1,20 @@
 package viewmodels
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"strings"
+	"sync"
 	"sync/atomic"
+	"time"
 
+	"github.com/marcuswu/msgpack/app"
+	"github.com/marcuswu/msgpack/app/files"
 	"github.com/marcuswu/msgpack/app/logic"
-	"github.com/vmihailenco/msgpack/v5"
-	"gopkg.in/yaml.v3"
+	"github.com/marcuswu/msgpack/mobile/router"
+	"github.com/marcuswu/msgpack/mobile/service"
+	"github.com/marcuswu/msgpack/mobile/viewmodel"
 )
 
 type structEdFormat int
@@ -33,15 +38,132 @@ type MsgPackViewerState struct {
 	Filename string
 	Data     *logic.Field
 	Error    error
-	format   structEdFormat
+	// Per codec failures when the file could not be decoded
+	DecodeErrors *logic.DecodeReport
+	// Set when Data only holds what could be decoded before the file became unparsable
+	Salvaged      bool
+	SalvageOffset int
+	SalvageTail   string
+	// Shape summary of Data, set while the summary screen is shown
+	Summary *logic.Stats
+	// Set by edits, cleared by a successful save
+	Dirty bool
+	// Result of the last Save / SaveAs; LastSaved is unix milliseconds, 0 if never saved
+	SaveError error
+	LastSaved int64
+	// Set when the file was changed outside of the app since it was loaded or saved.
+	// Resolve with ReloadFromDisk, MergeExternal or OverwriteDisk.
+	ExternalChange bool
+	// Paths both sides changed in the last MergeExternal
+	Conflicts *logic.Conflicts
+	// Progress of loading the document; Data is set once Load reaches service.Success.
+	// Only used w/in Go -- Ok to be skipped by gomobile
+	Load service.Resource[*logic.Field]
+	// Bytes of the file read so far; BytesTotal is -1 when the size isn't known
+	BytesRead  int64
+	BytesTotal int64
+	// Path of the node to show first, e.g. from a deep link. Set once the document has loaded and the node was found.
+	Focus  string
+	format structEdFormat
+	source *documentSource
 }
 
+/*
+documentSource is where the document came from: the bytes it was decoded from,
+the journal of its unsaved edits and the version on disk. It is published with the
+state so a load finishing in the background never writes to the view model itself.
+*/
+type documentSource struct {
+	fileData []byte
+	journal  *files.Journal
+	disk     *diskVersion
+}
+
+func (s *MsgPackViewerState) fileData() []byte {
+	if s.source == nil {
+		return nil
+	}
+	return s.source.fileData
+}
+
+// The journal unsaved edits are written to, or nil when they aren't journaled
+func (s *MsgPackViewerState) journal() *files.Journal {
+	if s.source == nil {
+		return nil
+	}
+	return s.source.journal
+}
+
+// The version of the file on disk, or nil when it isn't known
+func (s *MsgPackViewerState) disk() *diskVersion {
+	if s.source == nil {
+		return nil
+	}
+	return s.source.disk
+}
+
+// Reported by actions that need the document while it is still being loaded
+var ErrStillLoading = errors.New("the document is still loading")
+
+// Reported by actions that need the document when it could not be loaded
+var ErrNoDocument = errors.New("there is no document")
+
+// Why state's document can't be read or edited, nil when it can
+func documentError(state *MsgPackViewerState) error {
+	switch {
+	case state.Load.State == service.Loading:
+		return ErrStillLoading
+	case state.Data == nil || state.Load.State != service.Success:
+		return ErrNoDocument
+	}
+	return nil
+}
+
+// Limit on how much of an unparsable tail is rendered as hex
+const maxSalvageTailBytes = 4096
+
 func (s *MsgPackViewerState) Clone() *MsgPackViewerState {
 	data := s.Data
 	if data != nil {
 		data = s.Data.Clone()
 	}
-	return &MsgPackViewerState{Data: data, Error: s.Error, format: s.format}
+	load := s.Load
+	if load.Result != nil {
+		load.Result = data
+	}
+	return &MsgPackViewerState{
+		Filename:       s.Filename,
+		Data:           data,
+		Error:          s.Error,
+		DecodeErrors:   s.DecodeErrors,
+		Salvaged:       s.Salvaged,
+		SalvageOffset:  s.SalvageOffset,
+		SalvageTail:    s.SalvageTail,
+		Summary:        s.Summary,
+		Dirty:          s.Dirty,
+		SaveError:      s.SaveError,
+		LastSaved:      s.LastSaved,
+		ExternalChange: s.ExternalChange,
+		Conflicts:      s.Conflicts,
+		Load:           load,
+		BytesRead:      s.BytesRead,
+		BytesTotal:     s.BytesTotal,
+		Focus:          s.Focus,
+		format:         s.format,
+		source:         s.source,
+	}
+}
+
+// ErrorCode exposes the logic.ErrorKind of Error to the platform (0 when there is no typed error)
+func (s *MsgPackViewerState) ErrorCode() int {
+	return logic.ErrorCode(s.Error)
+}
+
+func (s *MsgPackViewerState) ErrorMessage() string {
+	if s.Error == nil {
+		return ""
+	}
+	return s.Error.Error()
 }
 
 type MsgPackStateFunc interface {
@@ -60,86 +182,296 @@ type MsgPackStateObserver interface {
 }
 
 type ViewerViewModel struct {
-	state     atomic.Value
-	observers map[string]MsgPackStateObserver
+	viewmodel.Lifecycle
+	state       atomic.Value
+	observersMu sync.RWMutex
+	observers   map[string]MsgPackStateObserver
+	backups     int
+	loading     viewerLoad
+	reads       *service.Runner[[]byte]
+	// Path of the node to show first, see NewViewerViewModelFromRoute. Taken by the first document loaded.
+	focus atomic.Pointer[string]
+	app   *app.Container
 }
 
+// Decoding happens in the background; the state's Load tracks it
 func NewViewerViewModel(fileData []byte) *ViewerViewModel {
-	vm := &ViewerViewModel{observers: make(map[string]MsgPackStateObserver)}
-	state := &MsgPackViewerState{Data: nil, Error: nil}
+	return NewViewerViewModelWith(app.Default(), fileData)
+}
 
-	log.Println("Creating ViewerViewModel")
-	// Try MsgPack first
-	data, err := vm.readMsgPack(fileData)
-	state.format = msgpackFormat
-	if err != nil {
-		data, err = vm.readJson(fileData)
-		state.format = jsonFormat
-		if err != nil {
-			data, err = vm.readYaml(fileData)
-			state.format = yamlFormat
-		}
+func NewViewerViewModelWith(container *app.Container, fileData []byte) *ViewerViewModel {
+	vm := newViewerViewModel(container)
+	vm.load("", bytesReader(fileData), vm.decodeFile(""))
+	return vm
+}
+
+/*
+Open filename (a path or platform uri) through the container's FileProvider so that Save can write back to it.
+The file is read and decoded in the background; the state's Load tracks it.
+*/
+func NewViewerViewModelFromFile(filename string) *ViewerViewModel {
+	return NewViewerViewModelFromFileWith(app.Default(), filename)
+}
+
+func NewViewerViewModelFromFileWith(container *app.Container, filename string) *ViewerViewModel {
+	vm := newViewerViewModel(container)
+	vm.LoadFile(filename)
+	return vm
+}
+
+/*
+Open the viewer route's file, restoring its interrupted session when the restore argument is set.
+With a path argument the state's Focus is set to it once the document has loaded,
+or Error says why the node couldn't be shown.
+*/
+func NewViewerViewModelFromRoute(args *router.Args) *ViewerViewModel {
+	return NewViewerViewModelFromRouteWith(app.Default(), args)
+}
+
+func NewViewerViewModelFromRouteWith(container *app.Container, args *router.Args) *ViewerViewModel {
+	vm := newViewerViewModel(container)
+	if focus := strings.Trim(args.GetString(app.PathArg), "/"); focus != "" {
+		vm.focus.Store(&focus)
 	}
+	if args.GetBool(app.RestoreArg) {
+		vm.restoreSession(args.GetString(app.FileArg))
+		return vm
+	}
+	vm.LoadFile(args.GetString(app.FileArg))
+	return vm
+}
+
+func newViewerViewModel(container *app.Container) *ViewerViewModel {
+	container = containerOrDefault(container)
+	container.Logger().Printf("Creating ViewerViewModel")
+	vm := &ViewerViewModel{observers: make(map[string]MsgPackStateObserver), backups: app.BackupCount.GetFrom(container.Config()), app: container}
+	// A failed read is reported rather than retried; the user can open the file again
+	vm.reads = service.NewRunner[[]byte](service.NoRetry)
+	vm.reads.SetCallback(container.ServiceCallback())
+	// Coming back to the screen may follow an edit made in another app
+	vm.OnStart(func() {
+		if vm.state.Load().(*MsgPackViewerState).Load.State == service.Success {
+			vm.CheckExternalChanges()
+		}
+	})
+	// A load still running when the screen goes away is cancelled and its result dropped
+	vm.OnClear(func() {
+		vm.loading.mu.Lock()
+		defer vm.loading.mu.Unlock()
+		vm.stopLoad()
+	})
+	return vm
+}
 
+// The state for a freshly decoded document; err is the decode failure, if any
+func (vm *ViewerViewModel) decodedState(filename string, fileData []byte, data *logic.Field, format structEdFormat, err *logic.DecodeReport) *MsgPackViewerState {
+	state := &MsgPackViewerState{Filename: filename, Data: nil, Error: nil}
+	state.source = &documentSource{fileData: fileData}
+	state.BytesRead = int64(len(fileData))
+	state.BytesTotal = int64(len(fileData))
+	state.format = format
 	if err != nil {
-		log.Printf("Failed unpack file: %s\n", err.Error())
+		vm.app.Logger().Printf("Failed unpack file: %s\n", err.Error())
 		state.Error = err
-		vm.UpdateState(state)
-		return vm
+		state.DecodeErrors = err
+		state.Load = service.Resource[*logic.Field]{State: service.Error, Error: err}
+		return vm.focusState(state)
 	}
 
 	numKeys := 0
-	log.Printf("Unpacked data: %v", data.DebugString())
+	vm.app.Logger().Printf("Unpacked data: %v", data.DebugString())
 	if m, _ := data.GetMap(); m != nil {
 		numKeys, _ = m.KeySizeAt("")
 	}
 	if a, _ := data.GetArray(); a != nil {
 		numKeys, _ = a.KeySizeAt("")
 	}
-	log.Printf("Detected encoding format %d", state.format)
-	log.Printf("Unpacked and set state data with %d keys", numKeys)
+	vm.app.Logger().Printf("Detected encoding format %d", state.format)
+	vm.app.Logger().Printf("Unpacked and set state data with %d keys", numKeys)
 	state.Data = data
-	vm.UpdateState(state)
-	return vm
+	state.Load = service.Resource[*logic.Field]{State: service.Success, Result: data}
+	state.source.journal = vm.openJournal(filename)
+	state.source.disk = vm.diskVersionOf(filename, fileData, data)
+	return vm.focusState(state)
+}
+
+/*
+Point state at the node the viewer was opened on, if it is in the document.
+Only the first document loaded is focused, whether or not it could be decoded,
+so reloading or discarding changes later leaves the user where they are.
+*/
+func (vm *ViewerViewModel) focusState(state *MsgPackViewerState) *MsgPackViewerState {
+	focus := vm.focus.Swap(nil)
+	if focus == nil || state.Data == nil {
+		return state
+	}
+	var err error
+	if a, _ := state.Data.GetArray(); a != nil {
+		_, err = a.GetPath(*focus)
+	} else if m, _ := state.Data.GetMap(); m != nil {
+		_, err = m.GetPath(*focus)
+	} else {
+		err = errors.New("the document is a single value")
+	}
+	if err != nil {
+		state.Error = fmt.Errorf("could not show %s in %s: %w", *focus, state.Filename, err)
+		return state
+	}
+	state.Focus = *focus
+	return state
+}
+
+func (vm *ViewerViewModel) decode(fileData []byte) (*logic.Field, structEdFormat, *logic.DecodeReport) {
+	data, format, err := vm.decodeContext(context.Background(), fileData)
+	if err != nil {
+		return nil, format, err.(*logic.DecodeReport)
+	}
+	return data, format, nil
+}
+
+/*
+decodeContext tries each format in turn, MsgPack first. It gives up with ctx's error when ctx is
+cancelled between attempts, otherwise a failure is the *logic.DecodeReport of every attempt.
+*/
+func (vm *ViewerViewModel) decodeContext(ctx context.Context, fileData []byte) (*logic.Field, structEdFormat, error) {
+	readers := []struct {
+		format structEdFormat
+		read   func([]byte) (*logic.Field, error)
+	}{
+		{msgpackFormat, vm.readMsgPack},
+		{jsonFormat, vm.readJson},
+		{yamlFormat, vm.readYaml},
+	}
+	report := logic.NewDecodeReport()
+	format := unknownFormat
+	for _, reader := range readers {
+		if err := ctx.Err(); err != nil {
+			return nil, format, err
+		}
+		format = reader.format
+		data, err := reader.read(fileData)
+		if err == nil {
+			return data, format, nil
+		}
+		report.Add(err.(*logic.DecodeError))
+	}
+	return nil, format, report
 }
 
 func (b *ViewerViewModel) readYaml(fileData []byte) (*logic.Field, error) {
-	data := make(map[string]interface{})
-	err := yaml.Unmarshal(fileData, &data)
+	data, err := logic.DecodeYaml(fileData)
 	if err != nil {
-		log.Printf("Failed unpack file: %s\n", err.Error())
+		b.app.Logger().Printf("Failed unpack file: %s\n", err.Error())
 		return nil, err
 	}
-	return logic.NewFieldWithValue("", data), nil
+	return data, nil
 }
 
 func (b *ViewerViewModel) readJson(fileData []byte) (*logic.Field, error) {
-	data := make(map[string]interface{})
-	err := json.Unmarshal(fileData, &data)
+	data, err := logic.DecodeJson(fileData)
 	if err != nil {
-		log.Printf("Failed unpack file: %s\n", err.Error())
+		b.app.Logger().Printf("Failed unpack file: %s\n", err.Error())
 		return nil, err
 	}
-	return logic.NewFieldWithValue("", data), nil
+	return data, nil
 }
 
 func (b *ViewerViewModel) readMsgPack(fileData []byte) (*logic.Field, error) {
-	data := make(map[string]interface{})
-	err := msgpack.Unmarshal(fileData, &data)
+	data, err := logic.DecodeMsgPack(fileData)
 	if err != nil {
-		log.Printf("Failed unpack file: %s\n", err.Error())
+		b.app.Logger().Printf("Failed unpack file: %s\n", err.Error())
 		return nil, err
 	}
-	return logic.NewFieldWithValue("", data), nil
+	return data, nil
+}
+
+/*
+Salvage shows whatever could be decoded from a damaged file.
+Every codec is tried and the one that got furthest into the file wins.
+Saving a salvaged document drops the unparsable tail.
+*/
+func (vm *ViewerViewModel) Salvage() {
+	state := vm.CloneState()
+	if state.Load.State == service.Loading {
+		state.Error = ErrStillLoading
+		vm.UpdateState(state)
+		return
+	}
+	if state.Data != nil && !state.Salvaged {
+		return
+	}
+	vm.UpdateState(vm.salvagedState(state))
+}
+
+// state with its document salvaged from the file's bytes
+func (vm *ViewerViewModel) salvagedState(state *MsgPackViewerState) *MsgPackViewerState {
+	fileData := state.fileData()
+
+	attempts := []struct {
+		format structEdFormat
+		result *logic.Salvage
+	}{
+		{msgpackFormat, logic.SalvageMsgPack(fileData)},
+		{jsonFormat, logic.SalvageJson(fileData)},
+		{yamlFormat, logic.SalvageYaml(fileData)},
+	}
+	// A map or array root beats a lone value however far each got; then the furthest wins, msgpack on ties
+	best := attempts[0]
+	for _, attempt := range attempts[1:] {
+		container, bestContainer := attempt.result.HasContainerRoot(), best.result.HasContainerRoot()
+		if container != bestContainer {
+			if container {
+				best = attempt
+			}
+			continue
+		}
+		if attempt.result.Offset > best.result.Offset {
+			best = attempt
+		}
+	}
+
+	vm.app.Logger().Printf("Salvaged %d of %d bytes as %s", best.result.Offset, len(fileData), best.result.Codec)
+	state.Data = best.result.Data
+	// What was salvaged can be browsed and edited like any loaded document
+	state.Load = service.Resource[*logic.Field]{State: service.Success, Result: state.Data}
+	state.format = best.format
+	state.Salvaged = true
+	state.SalvageOffset = best.result.Offset
+	state.SalvageTail = best.result.TailHex(maxSalvageTailBytes)
+	if best.result.Error != nil {
+		state.Error = best.result.Error
+	}
+	return state
 }
 
 func (b *ViewerViewModel) UpdateState(newState *MsgPackViewerState) {
+	b.storeState(newState)
+	b.notify()
+}
+
+func (b *ViewerViewModel) storeState(newState *MsgPackViewerState) {
 	oldState := b.state.Load()
 	fmt.Printf("Storing new state %p (old state %p)\n", newState, oldState)
 	b.state.Store(newState)
-	for _, sub := range b.observers {
-		sub.Update(b.state.Load().(*MsgPackViewerState))
-	}
+}
+
+/*
+Send observers the latest state. Loads publish from a background goroutine, so the
+observers are copied out under the lock and called without it; an observer may
+observe, cancel a load or clear the screen from its callback.
+*/
+func (b *ViewerViewModel) notify() {
+	b.Notify(func() {
+		b.observersMu.RLock()
+		observers := make([]MsgPackStateObserver, 0, len(b.observers))
+		for _, sub := range b.observers {
+			observers = append(observers, sub)
+		}
+		b.observersMu.RUnlock()
+		for _, sub := range observers {
+			sub.Update(b.state.Load().(*MsgPackViewerState))
+		}
+	})
 }
 
 func (b *ViewerViewModel) CloneState() *MsgPackViewerState {
@@ -155,45 +487,139 @@ func (b *ViewerViewModel) WithState(stateFunc MsgPackStateFunc) {
 }
 
 func (b *ViewerViewModel) Observe(id string, callback MsgPackStateObserver) {
+	b.observersMu.Lock()
+	defer b.observersMu.Unlock()
 	b.observers[id] = callback
 }
 
 func (vm *ViewerViewModel) FileData() []byte {
-	byteData := []byte{}
+	byteData, err := vm.encode()
+	if err != nil {
+		state := vm.CloneState()
+		state.Error = err
+		vm.UpdateState(state)
+		return nil
+	}
+	return byteData
+}
+
+func (vm *ViewerViewModel) encode() ([]byte, error) {
+	var byteData []byte
+	var err error
 	vm.WithState(&ViewerStateFunc{
 		StateFunc: func(state *MsgPackViewerState) {
-			var buf bytes.Buffer
-			var err error = nil
+			if err = documentError(state); err != nil {
+				return
+			}
 
 			fmt.Printf("Encoding format %d\n", state.format)
 			switch state.format {
 			case msgpackFormat:
 				fmt.Println("Encoding data to msgpack")
-				enc := msgpack.NewEncoder(&buf)
-				enc.SetSortMapKeys(true)
-				err = enc.Encode(state.Data.Value())
+				byteData, err = logic.EncodeMsgPack(state.Data.Value())
 			case yamlFormat:
 				fmt.Println("Encoding data to yaml")
-				enc := yaml.NewEncoder(&buf)
-				err = enc.Encode(state.Data.Value())
+				byteData, err = logic.EncodeYaml(state.Data.Value())
 			case jsonFormat:
 				fmt.Println("Encoding data to json")
-				enc := json.NewEncoder(&buf)
-				err = enc.Encode(state.Data.Value())
+				byteData, err = logic.EncodeJson(state.Data.Value())
 			}
 
 			if err != nil {
-				state.Error = fmt.Errorf("could not convert data: %v", err)
+				err = fmt.Errorf("could not convert data: %w", err)
 			}
-
-			byteData = buf.Bytes()
 		},
 	})
-	return byteData
+	return byteData, err
+}
+
+// How many rotated backups Save keeps (0 disables backups)
+func (vm *ViewerViewModel) SetBackupCount(backups int) {
+	if backups < 0 {
+		backups = 0
+	}
+	vm.backups = backups
+}
+
+// Save the document back to the file it was opened from
+func (vm *ViewerViewModel) Save() {
+	vm.SaveAs(vm.state.Load().(*MsgPackViewerState).Filename)
+}
+
+// Save the document, labelling the version kept in its history with message
+func (vm *ViewerViewModel) SaveWithMessage(message string) {
+	vm.saveAs(vm.state.Load().(*MsgPackViewerState).Filename, false, message)
+}
+
+/*
+SaveAs encodes the document in its current format and atomically replaces filename.
+The outcome is reported through SaveError / LastSaved, and on success the
+document takes filename as its Filename.
+*/
+func (vm *ViewerViewModel) SaveAs(filename string) {
+	vm.saveAs(filename, false, "")
+}
+
+/*
+Unless force is set, saving over the open file fails with ErrExternalChange if it was changed by someone else.
+message labels the version recorded in the file's history.
+*/
+func (vm *ViewerViewModel) saveAs(filename string, force bool, message string) {
+	var err error
+	var byteData []byte
+	current := vm.state.Load().(*MsgPackViewerState)
+	if filename == "" {
+		err = errors.New("no filename to save to")
+	} else if !force && filename == current.Filename {
+		var changed bool
+		if _, changed, err = vm.externalChange(filename, current.disk()); err == nil && changed {
+			err = ErrExternalChange
+		}
+	}
+	if err == nil {
+		byteData, err = vm.encode()
+	}
+	if err == nil {
+		err = vm.app.FileProvider().Write(filename, byteData, vm.backups)
+	}
+
+	state := vm.CloneState()
+	if errors.Is(err, ErrExternalChange) {
+		state.ExternalChange = true
+	}
+	if err != nil {
+		vm.app.Logger().Printf("Failed to save file: %s\n", err.Error())
+		state.SaveError = err
+		vm.UpdateState(state)
+		return
+	}
+	vm.app.Logger().Printf("Saved %d bytes to %s", len(byteData), filename)
+	if journal := state.journal(); journal != nil {
+		if err := journal.Discard(); err != nil {
+			vm.app.Logger().Printf("Failed to discard edit journal: %s\n", err.Error())
+		}
+	}
+	state.source = &documentSource{
+		fileData: byteData,
+		journal:  vm.openJournal(filename),
+		disk:     vm.diskVersionOf(filename, byteData, state.Data),
+	}
+	vm.recordVersion(filename, byteData, state.format, message)
+	state.Filename = filename
+	state.ExternalChange = false
+	state.SaveError = nil
+	state.Dirty = false
+	state.LastSaved = time.Now().UnixMilli()
+	vm.UpdateState(state)
 }
 
 func (vm *ViewerViewModel) GetPath(path string) *logic.Field {
 	state := vm.CloneState()
+	if err := documentError(state); err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return nil
+	}
 	a, err := state.Data.GetArray()
 	if err == nil {
 		val, err := a.GetPath(path)
@@ -221,31 +647,35 @@ func (vm *ViewerViewModel) GetPath(path string) *logic.Field {
 }
 
 func (vm *ViewerViewModel) SetPath(path string, field *logic.Field) {
+	previous := vm.state.Load().(*MsgPackViewerState)
 	state := vm.CloneState()
-	a, err := state.Data.GetArray()
+	err := documentError(state)
 	if err == nil {
-		err := a.SetPath(path, field)
-		if err != nil {
-			state.Error = err
-			vm.UpdateState(state)
-			return
-		}
+		err = setStatePath(state, path, field)
+	}
+	if err != nil {
+		state.Error = err
 		vm.UpdateState(state)
 		return
 	}
+	state.Dirty = true
+	vm.recordEdit(previous, state, &files.JournalEntry{Op: files.JournalSet, Path: path, Key: field.Key, Value: field.Value()})
+	vm.UpdateState(state)
+}
+
+func setStatePath(state *MsgPackViewerState, path string, field *logic.Field) error {
+	if state.Data == nil {
+		return ErrNoDocument
+	}
+	a, err := state.Data.GetArray()
+	if err == nil {
+		return a.SetPath(path, field)
+	}
 	m, err := state.Data.GetMap()
 	if err == nil {
-		err := m.SetPath(path, field)
-		if err != nil {
-			state.Error = err
-			vm.UpdateState(state)
-			return
-		}
-		vm.UpdateState(state)
-		return
+		return m.SetPath(path, field)
 	}
-	state.Error = err
-	vm.UpdateState(state)
+	return err
 }
 
 func (vm *ViewerViewModel) GetFormat() int {
@@ -254,6 +684,11 @@ func (vm *ViewerViewModel) GetFormat() int {
 
 func (vm *ViewerViewModel) SetFormat(format int) {
 	state := vm.CloneState()
+	if err := documentError(state); err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return
+	}
 	switch format {
 	case int(msgpackFormat):
 		state.format = msgpackFormat
@@ -262,5 +697,72 @@ func (vm *ViewerViewModel) SetFormat(format int) {
 	case int(jsonFormat):
 		state.format = jsonFormat
 	}
+	previous := vm.state.Load().(*MsgPackViewerState)
+	if state.format != previous.format {
+		state.Dirty = true
+		vm.recordEdit(previous, state, &files.JournalEntry{Op: files.JournalFormat, Format: int(state.format)})
+	}
+	vm.UpdateState(state)
+}
+
+// Encoded size of the value at path in msgpack, JSON and YAML
+func (vm *ViewerViewModel) SizeAt(path string) *logic.EncodedSize {
+	state := vm.CloneState()
+	if err := documentError(state); err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return nil
+	}
+	size, err := vm.sizeAtPath(state.Data, path)
+	if err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return nil
+	}
+	return size
+}
+
+func (vm *ViewerViewModel) sizeAtPath(data *logic.Field, path string) (*logic.EncodedSize, error) {
+	if a, err := data.GetArray(); err == nil {
+		return a.EncodedSizeAt(path)
+	}
+	m, err := data.GetMap()
+	if err != nil {
+		return nil, err
+	}
+	return m.EncodedSizeAt(path)
+}
+
+// Subtrees and key names ranked by how many bytes they add to the msgpack encoding
+func (vm *ViewerViewModel) SizeReport() *logic.SizeReport {
+	state := vm.CloneState()
+	if err := documentError(state); err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return nil
+	}
+	report, err := logic.NewSizeReport(state.Data.Value())
+	if err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return nil
+	}
+	return report
+}
+
+func (vm *ViewerViewModel) ShowSummary() {
+	state := vm.CloneState()
+	if err := documentError(state); err != nil {
+		state.Error = err
+		vm.UpdateState(state)
+		return
+	}
+	state.Summary = logic.NewStats(state.Data.Value())
+	vm.UpdateState(state)
+}
+
+func (vm *ViewerViewModel) HideSummary() {
+	state := vm.CloneState()
+	state.Summary = nil
 	vm.UpdateState(state)
 }
@@ -1,3 +1,3 @@
 #!/bin/bash
 
-gomobile bind -work -target android -androidapi 23 -o msgpack.aar github.com/marcuswu/msgpack/app github.com/marcuswu/msgpack/app/firebase github.com/marcuswu/msgpack/app/viewmodels github.com/marcuswu/msgpack/app/logic github.com/marcuswu/msgpack/mobile/router github.com/marcuswu/msgpack/mobile/state
+gomobile bind -work -target android -androidapi 23 -o msgpack.aar github.com/marcuswu/msgpack/app github.com/marcuswu/msgpack/app/firebase github.com/marcuswu/msgpack/app/files github.com/marcuswu/msgpack/app/viewmodels github.com/marcuswu/msgpack/app/logic github.com/marcuswu/msgpack/mobile/router github.com/marcuswu/msgpack/mobile/service github.com/marcuswu/msgpack/mobile/state github.com/marcuswu/msgpack/mobile/viewmodel
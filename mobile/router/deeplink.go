@@ -0,0 +1,112 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrBadLink     = errors.New("not a link to this app")
+	ErrUnknownLink = errors.New("unknown link")
+)
+
+// LinkError says which deep link couldn't be followed and why
+type LinkError struct {
+	Link string
+	err  error
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("deep link %s: %s", e.Link, e.err.Error())
+}
+
+func (e *LinkError) Unwrap() error {
+	return e.err
+}
+
+/*
+DeepLinks maps links from other apps and notifications, e.g. msgpack://open?file=...,
+to routes. The host of the link names an action; its query parameters become the
+route's arguments and are validated against the route table like any navigation.
+Links come from outside the app, so each action only accepts the parameters it was
+registered with, even when its route takes more.
+*/
+type DeepLinks struct {
+	mu      sync.Mutex
+	scheme  string
+	table   *RouteTable
+	actions map[string]*linkAction
+}
+
+type linkAction struct {
+	route  string
+	params map[string]bool
+}
+
+func NewDeepLinks(scheme string, table *RouteTable) *DeepLinks {
+	return &DeepLinks{scheme: scheme, table: table, actions: make(map[string]*linkAction)}
+}
+
+/*
+Follow links to action with route. params are the route arguments a link may set;
+any other parameter makes the link fail with ErrBadArgument.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func (d *DeepLinks) Register(action string, route string, params ...string) {
+	allowed := make(map[string]bool, len(params))
+	for _, param := range params {
+		allowed[param] = true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.actions[action] = &linkAction{route: route, params: allowed}
+}
+
+// Where link leads. Errors are a *LinkError wrapping ErrBadLink, ErrUnknownLink or the route's ErrBadArgument.
+func (d *DeepLinks) Parse(link string) (*Destination, error) {
+	fail := func(err error) (*Destination, error) {
+		return nil, &LinkError{Link: link, err: err}
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return fail(fmt.Errorf("%w: %s", ErrBadLink, err.Error()))
+	}
+	if !strings.EqualFold(u.Scheme, d.scheme) {
+		return fail(fmt.Errorf("%w: expected %s://", ErrBadLink, d.scheme))
+	}
+	// msgpack://open?... has the action as its host, msgpack:open?... as its opaque part
+	action := u.Host
+	if action == "" {
+		action = u.Opaque
+	}
+	action = strings.ToLower(action)
+
+	d.mu.Lock()
+	target, ok := d.actions[action]
+	d.mu.Unlock()
+	if !ok {
+		return fail(fmt.Errorf("%w: %q", ErrUnknownLink, action))
+	}
+
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return fail(fmt.Errorf("%w: %s", ErrBadArgument, err.Error()))
+	}
+	args := NewArgs()
+	for name, values := range query {
+		if !target.params[name] {
+			return fail(fmt.Errorf("%w: %s links can't set %s", ErrBadArgument, action, name))
+		}
+		if len(values) > 1 {
+			return fail(fmt.Errorf("%w: %s given more than once", ErrBadArgument, name))
+		}
+		args.SetString(name, values[0])
+	}
+	if err := d.table.Validate(target.route, args); err != nil {
+		return fail(err)
+	}
+	return &Destination{Name: target.route, Args: args}, nil
+}
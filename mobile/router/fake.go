@@ -0,0 +1,107 @@
+package router
+
+import "sync"
+
+/*
+FakeRouter is an in-memory Router for Go tests. It keeps the screens it has been
+sent to the way a platform would, and counts the calls made to it.
+*/
+type FakeRouter struct {
+	mu        sync.Mutex
+	screens   []string
+	navigates int
+	backs     int
+	exited    bool
+}
+
+func NewFakeRouter() *FakeRouter {
+	return &FakeRouter{}
+}
+
+func (r *FakeRouter) Navigate(location string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.screens = append(r.screens, location)
+	r.navigates++
+}
+
+// Going back from the last screen closes the app, as it would on Android
+func (r *FakeRouter) Back() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backs++
+	if len(r.screens) == 0 {
+		return
+	}
+	r.screens = r.screens[:len(r.screens)-1]
+	if len(r.screens) == 0 {
+		r.exited = true
+	}
+}
+
+// The location on screen, "" when there is none
+func (r *FakeRouter) Current() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.screens) == 0 {
+		return ""
+	}
+	return r.screens[len(r.screens)-1]
+}
+
+// The screens shown, the first one first
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (r *FakeRouter) Screens() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.screens...)
+}
+
+func (r *FakeRouter) NavigateCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.navigates
+}
+
+func (r *FakeRouter) BackCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.backs
+}
+
+// Whether going back ever left no screen
+func (r *FakeRouter) Exited() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.exited
+}
+
+/*
+FakeStackRouter is a FakeRouter that also implements StackRouter,
+for tests of platforms that can change their back stack in one step.
+*/
+type FakeStackRouter struct {
+	FakeRouter
+}
+
+func NewFakeStackRouter() *FakeStackRouter {
+	return &FakeStackRouter{}
+}
+
+func (r *FakeStackRouter) Replace(location string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.screens) > 0 {
+		r.screens = r.screens[:len(r.screens)-1]
+	}
+	r.screens = append(r.screens, location)
+}
+
+func (r *FakeStackRouter) PopCount(count int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if count > len(r.screens) {
+		count = len(r.screens)
+	}
+	r.screens = r.screens[:len(r.screens)-count]
+}
@@ -0,0 +1,279 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Types of route parameter
+const (
+	StringParam = iota
+	IntParam
+	BoolParam
+)
+
+func ParamTypeString(paramType int) string {
+	switch paramType {
+	case StringParam:
+		return "string"
+	case IntParam:
+		return "int"
+	case BoolParam:
+		return "bool"
+	}
+	return "unknown"
+}
+
+var (
+	ErrUnknownRoute = errors.New("unknown route")
+	ErrBadArgument  = errors.New("bad route argument")
+)
+
+type Param struct {
+	Name     string
+	Type     int
+	Required bool
+}
+
+// Route is a screen and the arguments it takes
+type Route struct {
+	Name   string
+	params []*Param
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewRoute(name string, params ...*Param) *Route {
+	return &Route{Name: name, params: params}
+}
+
+func (r *Route) ParamCount() int {
+	return len(r.params)
+}
+
+func (r *Route) GetParam(i int) *Param {
+	if i < 0 || i >= len(r.params) {
+		return nil
+	}
+	return r.params[i]
+}
+
+func (r *Route) FindParam(name string) *Param {
+	for _, param := range r.params {
+		if param.Name == name {
+			return param
+		}
+	}
+	return nil
+}
+
+/*
+Args are the arguments of a navigation, kept as strings so they cross into the platform
+as they are. The typed setters return the Args so calls can be chained.
+*/
+type Args struct {
+	values map[string]string
+}
+
+func NewArgs() *Args {
+	return &Args{values: make(map[string]string)}
+}
+
+func (a *Args) SetString(name string, value string) *Args {
+	a.values[name] = value
+	return a
+}
+
+func (a *Args) SetInt(name string, value int) *Args {
+	a.values[name] = strconv.Itoa(value)
+	return a
+}
+
+func (a *Args) SetBool(name string, value bool) *Args {
+	a.values[name] = strconv.FormatBool(value)
+	return a
+}
+
+func (a *Args) Has(name string) bool {
+	_, ok := a.values[name]
+	return ok
+}
+
+// "" when name isn't set
+func (a *Args) GetString(name string) string {
+	return a.values[name]
+}
+
+// 0 when name isn't set or isn't an int
+func (a *Args) GetInt(name string) int {
+	i, _ := strconv.Atoi(a.values[name])
+	return i
+}
+
+// false when name isn't set or isn't a bool
+func (a *Args) GetBool(name string) bool {
+	b, _ := strconv.ParseBool(a.values[name])
+	return b
+}
+
+// The argument names in order
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (a *Args) Names() []string {
+	names := make([]string, 0, len(a.values))
+	for name := range a.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+A location is the bindable form of a navigation: the route name followed by its
+arguments as a query string, e.g. viewer?file=%2Fdata%2Fa.json&restore=true.
+Router.Navigate receives locations; the platform turns them back into a route and
+arguments with RouteTable.Parse.
+*/
+func location(name string, args *Args) string {
+	if args == nil || len(args.values) == 0 {
+		return name
+	}
+	query := url.Values{}
+	for name, value := range args.values {
+		query.Set(name, value)
+	}
+	return name + "?" + query.Encode()
+}
+
+// Where a location leads: a route name and its arguments
+type Destination struct {
+	Name string
+	Args *Args
+}
+
+// RouteTable is the set of routes the app has, shared by Go and the platform
+type RouteTable struct {
+	mu     sync.Mutex
+	routes []*Route
+}
+
+func NewRouteTable() *RouteTable {
+	return &RouteTable{}
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (t *RouteTable) Register(route *Route) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, existing := range t.routes {
+		if existing.Name == route.Name {
+			t.routes[i] = route
+			return
+		}
+	}
+	t.routes = append(t.routes, route)
+}
+
+func (t *RouteTable) Size() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.routes)
+}
+
+func (t *RouteTable) Get(i int) *Route {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i < 0 || i >= len(t.routes) {
+		return nil
+	}
+	return t.routes[i]
+}
+
+// nil for an unknown route
+func (t *RouteTable) Find(name string) *Route {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, route := range t.routes {
+		if route.Name == name {
+			return route
+		}
+	}
+	return nil
+}
+
+// Check that args suit route name: required arguments are present and not empty, values parse as their type, and nothing unknown is passed
+func (t *RouteTable) Validate(name string, args *Args) error {
+	route := t.Find(name)
+	if route == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownRoute, name)
+	}
+	if args == nil {
+		args = NewArgs()
+	}
+	for _, param := range route.params {
+		value, ok := args.values[param.Name]
+		if param.Required && value == "" {
+			return fmt.Errorf("%w: %s needs %s", ErrBadArgument, name, param.Name)
+		}
+		if !ok {
+			continue
+		}
+		var err error
+		switch param.Type {
+		case IntParam:
+			_, err = strconv.Atoi(value)
+		case BoolParam:
+			_, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s %s should be %s, not %q", ErrBadArgument, name, param.Name, ParamTypeString(param.Type), value)
+		}
+	}
+	for _, argName := range args.Names() {
+		if route.FindParam(argName) == nil {
+			return fmt.Errorf("%w: %s doesn't take %s", ErrBadArgument, name, argName)
+		}
+	}
+	return nil
+}
+
+// The location for route name with args, once they have been validated
+func (t *RouteTable) Location(name string, args *Args) (string, error) {
+	if err := t.Validate(name, args); err != nil {
+		return "", err
+	}
+	return location(name, args), nil
+}
+
+// Turn a location back into a route and its arguments, validating them
+func (t *RouteTable) Parse(loc string) (*Destination, error) {
+	name, rawQuery, _ := strings.Cut(loc, "?")
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBadArgument, err.Error())
+	}
+	args := NewArgs()
+	for argName, values := range query {
+		if len(values) > 0 {
+			args.values[argName] = values[len(values)-1]
+		}
+	}
+	if err := t.Validate(name, args); err != nil {
+		return nil, err
+	}
+	return &Destination{Name: name, Args: args}, nil
+}
+
+// Validate and navigate to route name with args
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NavigateTo(r Router, table *RouteTable, name string, args *Args) error {
+	loc, err := table.Location(name, args)
+	if err != nil {
+		return err
+	}
+	r.Navigate(loc)
+	return nil
+}
@@ -0,0 +1,267 @@
+package router
+
+import (
+	"log"
+	"sync"
+)
+
+/*
+StackRouter is a Router that can change its back stack in one step.
+When the platform Router doesn't implement it, BackStack does the same
+with Back and Navigate, which goes back from the root screen for a moment on
+Replace and Clear. Platforms that close the app at that point should implement it.
+*/
+type StackRouter interface {
+	Router
+	// Show location in place of the current screen
+	Replace(string)
+	// Go back count screens at once
+	PopCount(int)
+}
+
+// StackState is a snapshot of a BackStack's locations, the root first
+type StackState struct {
+	entries []string
+}
+
+func (s *StackState) Size() int {
+	return len(s.entries)
+}
+
+func (s *StackState) Get(i int) string {
+	if i < 0 || i >= len(s.entries) {
+		return ""
+	}
+	return s.entries[i]
+}
+
+// The location on screen, "" when the stack is empty
+func (s *StackState) Top() string {
+	return s.Get(len(s.entries) - 1)
+}
+
+type StackObserver interface {
+	Update(*StackState)
+}
+
+// Where a BackStack reports navigation it ignored
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+/*
+BackStack keeps the screens navigated to in Go on top of the platform Router, so
+flows such as splash -> home -> viewer can be followed and asserted without the platform.
+Locations are validated against the route table before anything is navigated.
+BackStack is itself a Router: Navigate pushes and Back pops.
+When the platform handles the back button itself it calls Popped so the stack follows.
+*/
+type BackStack struct {
+	mu        sync.Mutex
+	router    Router
+	table     *RouteTable
+	entries   []string
+	observers map[string]StackObserver
+	logger    Logger
+}
+
+// Logs go to the log package until SetLogger is called
+func NewBackStack(router Router, table *RouteTable) *BackStack {
+	return &BackStack{router: router, table: table, observers: make(map[string]StackObserver), logger: log.Default()}
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (s *BackStack) SetLogger(logger Logger) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger = logger
+}
+
+func (s *BackStack) Observe(id string, observer StackObserver) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers[id] = observer
+}
+
+func (s *BackStack) StopObserving(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.observers, id)
+}
+
+func (s *BackStack) State() *StackState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snapshot()
+}
+
+func (s *BackStack) snapshot() *StackState {
+	return &StackState{entries: append([]string{}, s.entries...)}
+}
+
+// The route and arguments on screen, nil when the stack is empty
+func (s *BackStack) Top() *Destination {
+	top := s.State().Top()
+	if top == "" {
+		return nil
+	}
+	destination, err := s.table.Parse(top)
+	if err != nil {
+		return nil
+	}
+	return destination
+}
+
+// Implements Router; a location that isn't valid is logged and ignored
+func (s *BackStack) Navigate(location string) {
+	if err := s.Push(location); err != nil {
+		s.mu.Lock()
+		logger := s.logger
+		s.mu.Unlock()
+		logger.Printf("Could not navigate to %s: %s\n", location, err.Error())
+	}
+}
+
+// Implements Router
+func (s *BackStack) Back() {
+	s.Pop()
+}
+
+// Show location on top of the current screen
+func (s *BackStack) Push(location string) error {
+	if _, err := s.table.Parse(location); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.entries = append(s.entries, location)
+	s.mu.Unlock()
+	s.router.Navigate(location)
+	s.changed()
+	return nil
+}
+
+// Go back a screen. False when there is nothing to go back to.
+func (s *BackStack) Pop() bool {
+	s.mu.Lock()
+	if len(s.entries) <= 1 {
+		s.mu.Unlock()
+		return false
+	}
+	s.entries = s.entries[:len(s.entries)-1]
+	s.mu.Unlock()
+	s.router.Back()
+	s.changed()
+	return true
+}
+
+// The platform went back a screen on its own
+func (s *BackStack) Popped() {
+	s.mu.Lock()
+	if len(s.entries) == 0 {
+		s.mu.Unlock()
+		return
+	}
+	s.entries = s.entries[:len(s.entries)-1]
+	s.mu.Unlock()
+	s.changed()
+}
+
+// Show location in place of the current screen, e.g. so back from home doesn't return to splash
+func (s *BackStack) Replace(location string) error {
+	if _, err := s.table.Parse(location); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	replaced := len(s.entries) > 0
+	if replaced {
+		s.entries[len(s.entries)-1] = location
+	} else {
+		s.entries = append(s.entries, location)
+	}
+	s.mu.Unlock()
+	if replaced {
+		s.replace(location)
+	} else {
+		s.router.Navigate(location)
+	}
+	s.changed()
+	return nil
+}
+
+// Go back to the most recent screen showing route name. False when name isn't on the stack.
+func (s *BackStack) PopTo(name string) bool {
+	s.mu.Lock()
+	index := -1
+	for i := len(s.entries) - 1; i >= 0; i-- {
+		if destination, err := s.table.Parse(s.entries[i]); err == nil && destination.Name == name {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		s.mu.Unlock()
+		return false
+	}
+	count := len(s.entries) - 1 - index
+	s.entries = s.entries[:index+1]
+	s.mu.Unlock()
+	if count > 0 {
+		s.popCount(count)
+		s.changed()
+	}
+	return true
+}
+
+// Drop every screen and start again from location
+func (s *BackStack) Clear(location string) error {
+	if _, err := s.table.Parse(location); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	count := len(s.entries)
+	s.entries = []string{location}
+	s.mu.Unlock()
+	if count == 0 {
+		s.router.Navigate(location)
+	} else {
+		// Keep the root so the platform doesn't run out of screens, then replace it
+		if count > 1 {
+			s.popCount(count - 1)
+		}
+		s.replace(location)
+	}
+	s.changed()
+	return nil
+}
+
+func (s *BackStack) replace(location string) {
+	if stackRouter, ok := s.router.(StackRouter); ok {
+		stackRouter.Replace(location)
+		return
+	}
+	s.router.Back()
+	s.router.Navigate(location)
+}
+
+func (s *BackStack) popCount(count int) {
+	if stackRouter, ok := s.router.(StackRouter); ok {
+		stackRouter.PopCount(count)
+		return
+	}
+	for i := 0; i < count; i++ {
+		s.router.Back()
+	}
+}
+
+func (s *BackStack) changed() {
+	s.mu.Lock()
+	state := s.snapshot()
+	observers := make([]StackObserver, 0, len(s.observers))
+	for _, observer := range s.observers {
+		observers = append(observers, observer)
+	}
+	s.mu.Unlock()
+	for _, observer := range observers {
+		observer.Update(state)
+	}
+}
@@ -0,0 +1,242 @@
+package router
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func testTable() *RouteTable {
+	table := NewRouteTable()
+	table.Register(NewRoute("splash"))
+	table.Register(NewRoute("home"))
+	table.Register(NewRoute("viewer", &Param{Name: "file", Type: StringParam, Required: true}))
+	return table
+}
+
+// The routers under test: the plain one drives BackStack's Back / Navigate fallbacks
+func testRouters() map[string]func() (Router, *FakeRouter) {
+	return map[string]func() (Router, *FakeRouter){
+		"router": func() (Router, *FakeRouter) {
+			r := NewFakeRouter()
+			return r, r
+		},
+		"stack router": func() (Router, *FakeRouter) {
+			r := NewFakeStackRouter()
+			return r, &r.FakeRouter
+		},
+	}
+}
+
+func assertStack(t *testing.T, stack *BackStack, fake *FakeRouter, want ...string) {
+	t.Helper()
+	state := stack.State()
+	got := make([]string, state.Size())
+	for i := range got {
+		got[i] = state.Get(i)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("stack is %v, want %v", got, want)
+	}
+	if screens := fake.Screens(); !reflect.DeepEqual(screens, want) {
+		t.Fatalf("platform shows %v, want %v", screens, want)
+	}
+}
+
+func TestBackStackPushPop(t *testing.T) {
+	for name, newRouter := range testRouters() {
+		t.Run(name, func(t *testing.T) {
+			r, fake := newRouter()
+			stack := NewBackStack(r, testTable())
+			for _, location := range []string{"splash", "home", "viewer?file=a"} {
+				if err := stack.Push(location); err != nil {
+					t.Fatal(err)
+				}
+			}
+			assertStack(t, stack, fake, "splash", "home", "viewer?file=a")
+			if top := stack.Top(); top == nil || top.Name != "viewer" || top.Args.GetString("file") != "a" {
+				t.Fatalf("top is %+v", top)
+			}
+
+			if !stack.Pop() || !stack.Pop() {
+				t.Fatal("Pop failed with screens to go back to")
+			}
+			assertStack(t, stack, fake, "splash")
+			if stack.Pop() {
+				t.Fatal("Pop went back from the root")
+			}
+			if fake.Exited() {
+				t.Fatal("the platform was closed")
+			}
+		})
+	}
+}
+
+func TestBackStackPushInvalid(t *testing.T) {
+	r := NewFakeRouter()
+	stack := NewBackStack(r, testTable())
+	for _, location := range []string{"nowhere", "viewer", "viewer?file=a&other=b"} {
+		if err := stack.Push(location); err == nil {
+			t.Fatalf("pushed %s", location)
+		}
+	}
+	if stack.State().Size() != 0 || r.NavigateCount() != 0 {
+		t.Fatal("an invalid location was navigated to")
+	}
+}
+
+type logs []string
+
+func (l *logs) Printf(format string, v ...interface{}) {
+	*l = append(*l, fmt.Sprintf(format, v...))
+}
+
+func TestBackStackNavigateInvalidLogs(t *testing.T) {
+	r := NewFakeRouter()
+	stack := NewBackStack(r, testTable())
+	logged := &logs{}
+	stack.SetLogger(logged)
+	stack.Navigate("nowhere")
+	if len(*logged) != 1 || r.NavigateCount() != 0 {
+		t.Fatalf("logged %v after navigating %d times", *logged, r.NavigateCount())
+	}
+}
+
+func TestBackStackReplace(t *testing.T) {
+	for name, newRouter := range testRouters() {
+		t.Run(name, func(t *testing.T) {
+			r, fake := newRouter()
+			stack := NewBackStack(r, testTable())
+			// Replacing on an empty stack navigates
+			if err := stack.Replace("splash"); err != nil {
+				t.Fatal(err)
+			}
+			assertStack(t, stack, fake, "splash")
+			if err := stack.Replace("home"); err != nil {
+				t.Fatal(err)
+			}
+			assertStack(t, stack, fake, "home")
+			if stack.Pop() {
+				t.Fatal("splash is still on the stack")
+			}
+			if err := stack.Replace("viewer"); err == nil {
+				t.Fatal("replaced with an invalid location")
+			}
+			assertStack(t, stack, fake, "home")
+		})
+	}
+}
+
+func TestBackStackReplaceFallback(t *testing.T) {
+	r := NewFakeRouter()
+	stack := NewBackStack(r, testTable())
+	stack.Push("splash")
+	stack.Replace("home")
+	// Without StackRouter the platform goes back from splash then navigates home
+	if r.BackCount() != 1 || r.NavigateCount() != 2 {
+		t.Fatalf("%d backs and %d navigates", r.BackCount(), r.NavigateCount())
+	}
+
+	sr := NewFakeStackRouter()
+	stack = NewBackStack(sr, testTable())
+	stack.Push("splash")
+	stack.Replace("home")
+	if sr.BackCount() != 0 || sr.NavigateCount() != 1 {
+		t.Fatalf("%d backs and %d navigates", sr.BackCount(), sr.NavigateCount())
+	}
+}
+
+func TestBackStackPopTo(t *testing.T) {
+	for name, newRouter := range testRouters() {
+		t.Run(name, func(t *testing.T) {
+			r, fake := newRouter()
+			stack := NewBackStack(r, testTable())
+			for _, location := range []string{"home", "viewer?file=a", "viewer?file=b", "viewer?file=c"} {
+				stack.Push(location)
+			}
+			if stack.PopTo("splash") {
+				t.Fatal("popped to a screen that isn't on the stack")
+			}
+			assertStack(t, stack, fake, "home", "viewer?file=a", "viewer?file=b", "viewer?file=c")
+			// The most recent match is kept
+			if !stack.PopTo("viewer") {
+				t.Fatal("PopTo the top failed")
+			}
+			assertStack(t, stack, fake, "home", "viewer?file=a", "viewer?file=b", "viewer?file=c")
+			if !stack.PopTo("home") {
+				t.Fatal("PopTo home failed")
+			}
+			assertStack(t, stack, fake, "home")
+		})
+	}
+}
+
+func TestBackStackPopCountFallback(t *testing.T) {
+	r := NewFakeRouter()
+	stack := NewBackStack(r, testTable())
+	for _, location := range []string{"home", "viewer?file=a", "viewer?file=b"} {
+		stack.Push(location)
+	}
+	stack.PopTo("home")
+	if r.BackCount() != 2 {
+		t.Fatalf("went back %d times", r.BackCount())
+	}
+
+	sr := NewFakeStackRouter()
+	stack = NewBackStack(sr, testTable())
+	for _, location := range []string{"home", "viewer?file=a", "viewer?file=b"} {
+		stack.Push(location)
+	}
+	stack.PopTo("home")
+	if sr.BackCount() != 0 {
+		t.Fatalf("went back %d times", sr.BackCount())
+	}
+}
+
+func TestBackStackClear(t *testing.T) {
+	for name, newRouter := range testRouters() {
+		t.Run(name, func(t *testing.T) {
+			r, fake := newRouter()
+			stack := NewBackStack(r, testTable())
+			if err := stack.Clear("splash"); err != nil {
+				t.Fatal(err)
+			}
+			assertStack(t, stack, fake, "splash")
+			stack.Push("home")
+			stack.Push("viewer?file=a")
+			if err := stack.Clear("home"); err != nil {
+				t.Fatal(err)
+			}
+			assertStack(t, stack, fake, "home")
+			if err := stack.Clear("nowhere"); err == nil {
+				t.Fatal("cleared to an invalid location")
+			}
+			assertStack(t, stack, fake, "home")
+		})
+	}
+}
+
+type stackObserver struct {
+	updates []*StackState
+}
+
+func (o *stackObserver) Update(state *StackState) {
+	o.updates = append(o.updates, state)
+}
+
+func TestBackStackObserve(t *testing.T) {
+	stack := NewBackStack(NewFakeRouter(), testTable())
+	observer := &stackObserver{}
+	stack.Observe("test", observer)
+	stack.Push("home")
+	stack.Push("viewer?file=a")
+	stack.Popped()
+	stack.StopObserving("test")
+	stack.Push("viewer?file=b")
+	if len(observer.updates) != 3 {
+		t.Fatalf("observed %d updates", len(observer.updates))
+	}
+	if top := observer.updates[2].Top(); top != "home" {
+		t.Fatalf("after Popped the top is %s", top)
+	}
+}
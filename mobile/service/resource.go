@@ -12,6 +12,7 @@ const (
 A Service is just a go routine
 A Resource, maintains the state of the go routine (the service call state)
 On completion, the Resource sets Error or Result
+Runner starts services and moves their Resources through these states
 */
 type Resource[T any] struct {
 	State  LoadingState
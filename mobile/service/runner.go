@@ -0,0 +1,305 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+/*
+ResourceCallback is told about every state change of the services a Runner starts,
+so the platform can follow them without knowing their Go result types.
+state is one of Loading, Success or Error; message is the error text for Error.
+*/
+type ResourceCallback interface {
+	OnResourceChanged(key string, state int, message string)
+}
+
+type ResourceChangedFunc struct {
+	Callback func(key string, state int, message string)
+}
+
+func (cb *ResourceChangedFunc) OnResourceChanged(key string, state int, message string) {
+	cb.Callback(key, state, message)
+}
+
+/*
+RetryPolicy says how often a failed service is tried again.
+The delay before each retry grows by Multiplier up to MaxDelay, with up to
+Jitter (a fraction of the delay) added at random so retries don't line up.
+*/
+type RetryPolicy struct {
+	Attempts     int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	Jitter       float64
+}
+
+// Run once, never retry
+var NoRetry = RetryPolicy{Attempts: 1}
+
+// Three attempts, waiting about 0.5s then 1s between them
+var DefaultRetry = RetryPolicy{Attempts: 3, InitialDelay: 500 * time.Millisecond, MaxDelay: 10 * time.Second, Multiplier: 2, Jitter: 0.2}
+
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := float64(p.InitialDelay)
+	for i := 0; i < retry; i++ {
+		delay *= p.Multiplier
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Wrap an error a service returns to stop it being retried, e.g. a file that doesn't exist. The Resource holds err itself.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Work is the body of a service. It should give up promptly once ctx is done.
+type Work[T any] func(ctx context.Context) (T, error)
+
+/*
+Runner starts services as goroutines and keeps a Resource for each.
+Services are identified by a key: starting a service while one with the same key is
+still running joins the running one rather than doing the work twice.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+type Runner[T any] struct {
+	mu       sync.Mutex
+	retry    RetryPolicy
+	callback ResourceCallback
+	inFlight map[string]*call[T]
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func NewRunner[T any](retry RetryPolicy) *Runner[T] {
+	if retry.Attempts < 1 {
+		retry.Attempts = 1
+	}
+	return &Runner[T]{retry: retry, inFlight: make(map[string]*call[T])}
+}
+
+// Set the callback told about every service this runner starts
+func (r *Runner[T]) SetCallback(callback ResourceCallback) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.callback = callback
+}
+
+// One running service, shared by every Task that asked for it
+type call[T any] struct {
+	key       string
+	cancel    context.CancelFunc
+	done      chan struct{}
+	resource  Resource[T]
+	listeners map[*Task[T]]func(Resource[T])
+}
+
+/*
+Task is a caller's handle on a service.
+Cancelling a Task detaches that caller; the service itself is cancelled once
+every Task sharing it has been cancelled.
+*/
+type Task[T any] struct {
+	runner *Runner[T]
+	call   *call[T]
+	stop   func() bool
+}
+
+/*
+Run starts work under key, or joins the service already running under key.
+onChange, when not nil, is called with the final Resource once the service succeeds
+or fails, and before that with Loading when this call started the service.
+When ctx is done the returned Task is cancelled.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func (r *Runner[T]) Run(ctx context.Context, key string, work Work[T], onChange func(Resource[T])) *Task[T] {
+	r.mu.Lock()
+	c, joined := r.inFlight[key]
+	var workCtx context.Context
+	if !joined {
+		var cancel context.CancelFunc
+		workCtx, cancel = context.WithCancel(context.Background())
+		c = &call[T]{
+			key:       key,
+			cancel:    cancel,
+			done:      make(chan struct{}),
+			resource:  Resource[T]{State: Loading},
+			listeners: make(map[*Task[T]]func(Resource[T])),
+		}
+		r.inFlight[key] = c
+	}
+	task := &Task[T]{runner: r, call: c}
+	c.listeners[task] = onChange
+	task.stop = context.AfterFunc(ctx, task.Cancel)
+	callback := r.callback
+	r.mu.Unlock()
+
+	if joined {
+		return task
+	}
+	// Loading is reported before the work starts so it can't arrive after the result
+	if callback != nil {
+		callback.OnResourceChanged(key, int(Loading), "")
+	}
+	if onChange != nil {
+		onChange(Resource[T]{State: Loading})
+	}
+	go r.run(workCtx, c, work)
+	return task
+}
+
+func (r *Runner[T]) run(ctx context.Context, c *call[T], work Work[T]) {
+	var result T
+	var err error
+	for attempt := 0; attempt < r.retry.Attempts; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(r.retry.delay(attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		result, err = work(ctx)
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			err = permanent.err
+			break
+		}
+		if err == nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	resource := Resource[T]{State: Success, Result: result}
+	message := ""
+	if err != nil {
+		resource = Resource[T]{State: Error, Error: err}
+		message = err.Error()
+	}
+	r.finish(c, resource, message)
+}
+
+func (r *Runner[T]) finish(c *call[T], resource Resource[T], message string) {
+	r.mu.Lock()
+	c.resource = resource
+	if r.inFlight[c.key] == c {
+		delete(r.inFlight, c.key)
+	}
+	listeners := make([]func(Resource[T]), 0, len(c.listeners))
+	for task, onChange := range c.listeners {
+		task.stop()
+		if onChange != nil {
+			listeners = append(listeners, onChange)
+		}
+	}
+	c.listeners = nil
+	callback := r.callback
+	c.cancel()
+	close(c.done)
+	r.mu.Unlock()
+
+	for _, onChange := range listeners {
+		onChange(resource)
+	}
+	if callback != nil {
+		callback.OnResourceChanged(c.key, int(resource.State), message)
+	}
+}
+
+// Whether a service is running under key
+func (r *Runner[T]) Running(key string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.inFlight[key]
+	return ok
+}
+
+// Cancel the service running under key, whoever is waiting on it
+func (r *Runner[T]) Cancel(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.inFlight[key]; ok {
+		r.abandon(c)
+	}
+}
+
+// Cancel every running service, e.g. when the screen that started them goes away
+func (r *Runner[T]) CancelAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.inFlight {
+		r.abandon(c)
+	}
+}
+
+// Cancel c and let the next Run for its key start afresh rather than join it. Callers hold r.mu.
+func (r *Runner[T]) abandon(c *call[T]) {
+	c.cancel()
+	if r.inFlight[c.key] == c {
+		delete(r.inFlight, c.key)
+	}
+}
+
+// Detach from the service, cancelling it when no other Task is waiting on it. onChange isn't called again.
+func (t *Task[T]) Cancel() {
+	t.runner.mu.Lock()
+	defer t.runner.mu.Unlock()
+	if t.call.listeners == nil {
+		return
+	}
+	t.stop()
+	delete(t.call.listeners, t)
+	if len(t.call.listeners) == 0 {
+		t.runner.abandon(t.call)
+	}
+}
+
+// The Resource as it stands now
+func (t *Task[T]) Resource() Resource[T] {
+	t.runner.mu.Lock()
+	defer t.runner.mu.Unlock()
+	return t.call.resource
+}
+
+// Block until the service finishes and return its Resource
+func (t *Task[T]) Wait() Resource[T] {
+	<-t.call.done
+	return t.Resource()
+}
+
+// A channel closed when the service finishes
+func (t *Task[T]) Done() <-chan struct{} {
+	return t.call.done
+}
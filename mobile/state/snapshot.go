@@ -0,0 +1,33 @@
+package state
+
+import (
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+/*
+A UIState can be written to a compact []byte and restored after the platform kills the process
+(Android's onSaveInstanceState / onCreate bundle).
+By default the exported fields are encoded with msgpack. Fields that can't or shouldn't be
+restored (errors, large documents, values reloaded from disk) are tagged `msgpack:"-"`.
+States needing more control implement Snapshotter.
+*/
+type Snapshotter interface {
+	UIState
+	Snapshot() ([]byte, error)
+	Restore([]byte) error
+}
+
+func Marshal(s UIState) ([]byte, error) {
+	if snapshotter, ok := s.(Snapshotter); ok {
+		return snapshotter.Snapshot()
+	}
+	return msgpack.Marshal(s)
+}
+
+// Unmarshal overlays data onto s, so fields that weren't saved keep their current value
+func Unmarshal(data []byte, s UIState) error {
+	if snapshotter, ok := s.(Snapshotter); ok {
+		return snapshotter.Restore(data)
+	}
+	return msgpack.Unmarshal(data, s)
+}
@@ -0,0 +1,184 @@
+package viewmodel
+
+import (
+	"context"
+	"sync"
+)
+
+// Lifecycle states, in the order a view model goes through them
+const (
+	Created = iota
+	Started
+	Stopped
+	Cleared
+)
+
+func LifecycleStateString(state int) string {
+	switch state {
+	case Created:
+		return "created"
+	case Started:
+		return "started"
+	case Stopped:
+		return "stopped"
+	case Cleared:
+		return "cleared"
+	}
+	return "unknown"
+}
+
+/*
+Lifecycle follows the screen a view model belongs to. The platform calls
+Start and Stop as the screen becomes visible and hidden (Android's onStart / onStop)
+and Clear once the screen is gone for good (ViewModel.onCleared).
+
+Work a view model starts in the background should use Context, which is cancelled
+on Clear, or be started with Go. Clear doesn't wait for that work to end, as it is
+called on the main thread; work should check its context and drop its result once
+cancelled. Hooks registered with OnStart, OnStop and OnClear run on the matching transition.
+The zero value is ready to use; embed it in a view model.
+*/
+type Lifecycle struct {
+	mu      sync.Mutex
+	state   int
+	ctx     context.Context
+	cancel  context.CancelFunc
+	onStart []func()
+	onStop  []func()
+	onClear []func()
+}
+
+func (l *Lifecycle) LifecycleState() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state
+}
+
+func (l *Lifecycle) IsCleared() bool {
+	return l.LifecycleState() == Cleared
+}
+
+/*
+Run notify, which sends the view model's observers its latest state, unless the
+view model has been cleared: observers aren't updated once the screen is gone.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func (l *Lifecycle) Notify(notify func()) {
+	if l.IsCleared() {
+		return
+	}
+	notify()
+}
+
+// Scope of the view model's background work, cancelled on Clear
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (l *Lifecycle) Context() context.Context {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.context()
+}
+
+// Callers hold l.mu
+func (l *Lifecycle) context() context.Context {
+	if l.ctx == nil {
+		l.ctx, l.cancel = context.WithCancel(context.Background())
+		if l.state == Cleared {
+			l.cancel()
+		}
+	}
+	return l.ctx
+}
+
+/*
+Run work in a goroutine scoped to the view model: its context is cancelled on Clear.
+Nothing is run once the view model has been cleared.
+Only used w/in Go -- Ok to be skipped by gomobile
+*/
+func (l *Lifecycle) Go(work func(ctx context.Context)) {
+	l.mu.Lock()
+	if l.state == Cleared {
+		l.mu.Unlock()
+		return
+	}
+	ctx := l.context()
+	l.mu.Unlock()
+	go work(ctx)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (l *Lifecycle) OnStart(hook func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onStart = append(l.onStart, hook)
+}
+
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (l *Lifecycle) OnStop(hook func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onStop = append(l.onStop, hook)
+}
+
+// Hooks run in reverse order of registration, after the context is cancelled
+// Only used w/in Go -- Ok to be skipped by gomobile
+func (l *Lifecycle) OnClear(hook func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onClear = append(l.onClear, hook)
+}
+
+// The screen became visible. Ignored once cleared or while already started.
+func (l *Lifecycle) Start() {
+	if hooks := l.transition(Started, l.onStart); hooks != nil {
+		runHooks(hooks, false)
+	}
+}
+
+// The screen was hidden. Ignored unless started.
+func (l *Lifecycle) Stop() {
+	if hooks := l.transition(Stopped, l.onStop); hooks != nil {
+		runHooks(hooks, false)
+	}
+}
+
+// The screen is gone: cancel the context and run the clear hooks. Only the first call does anything.
+func (l *Lifecycle) Clear() {
+	l.mu.Lock()
+	if l.state == Cleared {
+		l.mu.Unlock()
+		return
+	}
+	l.state = Cleared
+	if l.cancel != nil {
+		l.cancel()
+	}
+	hooks := l.onClear
+	l.onStart, l.onStop, l.onClear = nil, nil, nil
+	l.mu.Unlock()
+
+	runHooks(hooks, true)
+}
+
+// Move to state, returning the hooks to run or nil when the move doesn't apply
+func (l *Lifecycle) transition(state int, hooks []func()) []func() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.state == Cleared, l.state == state:
+		return nil
+	case state == Stopped && l.state != Started:
+		return nil
+	}
+	l.state = state
+	return append([]func(){}, hooks...)
+}
+
+func runHooks(hooks []func(), reverse bool) {
+	for i := range hooks {
+		if reverse {
+			hooks[len(hooks)-1-i]()
+		} else {
+			hooks[i]()
+		}
+	}
+}
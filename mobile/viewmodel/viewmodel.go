@@ -43,15 +43,18 @@ type StateObserver[S state.UIState] interface {
 }
 
 type BaseViewModel[S state.UIState] struct {
+	Lifecycle
 	state     atomic.Value
 	observers map[string]StateObserver[S]
 }
 
 func (b *BaseViewModel[S]) UpdateState(newState S) {
 	b.state.Store(newState)
-	for _, sub := range b.observers {
-		sub.Update(b.state.Load().(S))
-	}
+	b.Notify(func() {
+		for _, sub := range b.observers {
+			sub.Update(b.state.Load().(S))
+		}
+	})
 }
 
 func (b *BaseViewModel[S]) CloneState() S {
@@ -66,6 +69,23 @@ func (b *BaseViewModel[S]) Observe(id string, callback StateObserver[S]) {
 	b.observers[id] = callback
 }
 
+func (b *BaseViewModel[S]) StopObserving(id string) {
+	delete(b.observers, id)
+}
+
 func (b *BaseViewModel[S]) ReadState() S {
 	return b.state.Load().(S)
 }
+
+func (b *BaseViewModel[S]) SaveInstanceState() ([]byte, error) {
+	return state.Marshal(b.state.Load().(S))
+}
+
+func (b *BaseViewModel[S]) RestoreInstanceState(data []byte) error {
+	newState := b.CloneState()
+	if err := state.Unmarshal(data, newState); err != nil {
+		return err
+	}
+	b.UpdateState(newState)
+	return nil
+}